@@ -22,6 +22,8 @@ var rootCmd = &cobra.Command{
     • VLESS  - 新一代轻量级代理协议
     • VMess  - V2Ray 传统代理协议
     • Shadowsocks (SS) - 经典代理协议
+    • Trojan - 基于 TLS 伪装的代理协议
+    • Hysteria2 / TUIC - 基于 QUIC 的 UDP 代理协议
   
   ` + color.WhiteString(`主要特性:`) + `
     • 🔥 并发测试，速度快
@@ -1,22 +1,47 @@
 package cmd
 
 import (
+    "context"
+    "errors"
     "fmt"
     "os"
+    "os/signal"
     "proxy-tester/internal/display"
     "proxy-tester/internal/fetcher"
     "proxy-tester/internal/parser"
     "proxy-tester/internal/tester"
+    "strings"
+    "syscall"
+    "time"
 
     "github.com/fatih/color"
     "github.com/spf13/cobra"
 )
 
 var (
-    subscriptionURL string
-    concurrency     int
-    timeout         int
-    verbose         bool
+    subscriptionURL  string
+    configFile       string
+    concurrency      int
+    timeout          int
+    verbose          bool
+    userAgent        string
+    probeURL         string
+    probeMethod      string
+    expectStatus     int
+    speedTest        bool
+    speedURL         string
+    speedTime        int
+    speedSize        int
+    speedConcurrency int
+    uploadTest       bool
+    uploadURL        string
+    uploadSize       int
+    samples          int
+    sampleInterval   time.Duration
+    sortBy           string
+    icmpTest         bool
+    runDeadline      time.Duration
+    nodeBudget       time.Duration
 )
 
 // 定义颜色函数
@@ -36,16 +61,36 @@ var (
 var testCmd = &cobra.Command{
     Use:   "test",
     Short: "测试订阅链接中的所有节点",
-    Long:  `从订阅链接下载节点信息，解析并并发测试所有节点的连通性和延迟。`,
+    Long:  `从订阅链接或本地配置文件读取节点信息，解析并并发测试所有节点的连通性和延迟。`,
     Run:   runTest,
 }
 
 func init() {
-    testCmd.Flags().StringVarP(&subscriptionURL, "url", "u", "", "订阅链接URL (必需)")
+    testCmd.Flags().StringVarP(&subscriptionURL, "url", "u", "", "订阅链接URL")
+    testCmd.Flags().StringVarP(&configFile, "file", "f", "", "本地订阅或 sing-box/Xray 配置文件路径")
     testCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 10, "并发测试数量")
     testCmd.Flags().IntVarP(&timeout, "timeout", "t", 5, "超时时间(秒)")
+    testCmd.Flags().DurationVar(&runDeadline, "deadline", 0, "整个运行的总时限，到达后跳过未完成的节点 (如 10m，0 表示不限)")
+    testCmd.Flags().DurationVar(&nodeBudget, "node-budget", 0, "单个节点全部阶段、采样与测速的总时限 (如 15s，0 表示不限)")
     testCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "显示详细日志")
-    testCmd.MarkFlagRequired("url")
+    testCmd.Flags().StringVar(&userAgent, "user-agent", "", "下载订阅时使用的 User-Agent (如 clash.meta 可获取 Clash 配置)")
+    testCmd.Flags().StringVar(&probeURL, "probe-url", tester.DefaultProbeURL, "经代理请求的探测地址 (http/https)")
+    testCmd.Flags().StringVar(&probeMethod, "probe-method", "GET", "探测请求方法 (GET 或 HEAD)")
+    testCmd.Flags().IntVar(&expectStatus, "expect-status", 204, "探测响应的期望状态码 (0 表示不校验)")
+    testCmd.Flags().BoolVar(&icmpTest, "icmp", false, "同时进行 ICMP Echo 测试，区分主机不可达与端口被过滤")
+    testCmd.Flags().IntVar(&samples, "samples", 1, "每个节点的采样次数，多次采样时统计最小/中位/平均/P95/最大延迟与抖动")
+    testCmd.Flags().DurationVar(&sampleInterval, "interval", time.Second, "同一节点两次采样之间的间隔 (如 500ms)")
+    testCmd.Flags().StringVar(&sortBy, "sort", "median", "多次采样时排序所用的统计量 (min/median/avg/p95/max/jitter)")
+    testCmd.Flags().BoolVar(&speedTest, "speed", false, "对连接成功的节点进行下载测速")
+    testCmd.Flags().StringVar(&speedURL, "speed-url", tester.DefaultSpeedURL, "测速下载地址 (http/https)")
+    testCmd.Flags().IntVar(&speedTime, "speed-time", 10, "单个节点下载/上传测速的最长时间(秒)")
+    testCmd.Flags().IntVar(&speedSize, "speed-size", 0, "单个节点的最大下载量(MB, 0 表示不限)")
+    testCmd.Flags().IntVar(&speedConcurrency, "speed-concurrency", 1, "同时测速的节点数量")
+    testCmd.Flags().BoolVar(&uploadTest, "upload", false, "对连接成功的节点进行上传测速")
+    testCmd.Flags().StringVar(&uploadURL, "upload-url", tester.DefaultUploadURL, "上传测速地址，接收 POST 请求体 (http/https)")
+    testCmd.Flags().IntVar(&uploadSize, "upload-size", 10, "单个节点的上传数据量(MB)")
+    testCmd.MarkFlagsOneRequired("url", "file")
+    testCmd.MarkFlagsMutuallyExclusive("url", "file")
 }
 
 func runTest(cmd *cobra.Command, args []string) {
@@ -63,6 +108,49 @@ func runTest(cmd *cobra.Command, args []string) {
     // 打印欢迎横幅
     printBanner()
     
+    opts := tester.NewOptions()
+    if err := opts.SetProbe(probeURL, probeMethod, expectStatus); err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("探测参数错误: %v", err)))
+        os.Exit(1)
+    }
+    if err := opts.SetSampling(samples, sampleInterval); err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("采样参数错误: %v", err)))
+        os.Exit(1)
+    }
+    if runDeadline < 0 {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("时限参数错误: 无效的总时限: %s", runDeadline)))
+        os.Exit(1)
+    }
+    if err := opts.SetNodeBudget(nodeBudget); err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("时限参数错误: %v", err)))
+        os.Exit(1)
+    }
+    sortStat, err := tester.ParseStatistic(sortBy)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("排序参数错误: %v", err)))
+        os.Exit(1)
+    }
+    display.SetSortStatistic(sortStat)
+    if icmpTest {
+        if err := opts.SetICMP(true); errors.Is(err, tester.ErrICMPFamilyUnavailable) {
+            fmt.Printf("  %s %s\n", yellow("⚠"), yellow(fmt.Sprintf("ICMP 测试部分可用，相应地址族的节点已跳过: %v", err)))
+        } else if err != nil {
+            fmt.Printf("  %s %s\n", yellow("⚠"), yellow(fmt.Sprintf("ICMP 测试不可用，已跳过: %v", err)))
+        }
+    }
+    if speedTest {
+        if err := opts.SetSpeedTest(speedURL, time.Duration(speedTime)*time.Second, int64(speedSize)<<20); err != nil {
+            fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("测速参数错误: %v", err)))
+            os.Exit(1)
+        }
+    }
+    if uploadTest {
+        if err := opts.SetUploadTest(uploadURL, time.Duration(speedTime)*time.Second, int64(uploadSize)<<20); err != nil {
+            fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("测速参数错误: %v", err)))
+            os.Exit(1)
+        }
+    }
+    
     // 显示代理绕过提示
     if verbose {
         fmt.Printf("  %s %s\n", cyan("ℹ"), gray("已启用代理绕过模式，所有连接将直连目标服务器"))
@@ -70,31 +158,30 @@ func runTest(cmd *cobra.Command, args []string) {
         fmt.Println()
     }
 
-    // 1. 下载订阅
-    if verbose {
-        fmt.Printf("  %s %s\n", cyanB("→"), white("正在从 URL 下载订阅..."))
-        fmt.Printf("    %s\n\n", gray(subscriptionURL))
+    // 收到 Ctrl-C 或 SIGTERM 后停止派发并取消进行中的测试，仍显示已完成的结果；
+    // 收到信号后恢复默认处理，再次按下 Ctrl-C 可立即退出
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    context.AfterFunc(ctx, stop)
+
+    // 总时限涵盖下载订阅、延迟测试与测速，到达后未完成的节点标记为跳过
+    if runDeadline > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, runDeadline)
+        defer cancel()
+    }
+
+    // 1. 获取订阅内容
+    var content string
+    if configFile != "" {
+        content, err = loadFile()
     } else {
-        fmt.Printf("  %s %s\n\n", cyanB("→"), white("正在从 URL 下载订阅..."))
+        content, err = downloadSubscription(ctx)
     }
-    
-    content, err := fetcher.FetchSubscription(subscriptionURL)
     if err != nil {
-        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("下载订阅失败: %v", err)))
         os.Exit(1)
     }
 
-    if verbose {
-        fmt.Printf("  %s %s\n", greenB("✓"), white(fmt.Sprintf("下载成功，内容长度: %d 字节", len(content))))
-        preview := content
-        if len(preview) > 100 {
-            preview = preview[:100] + "..."
-        }
-        fmt.Printf("    %s\n\n", gray(fmt.Sprintf("内容预览: %s", preview)))
-    } else {
-        fmt.Printf("  %s %s\n\n", greenB("✓"), white("下载成功"))
-    }
-
     // 2. 解析节点
     if verbose {
         fmt.Printf("  %s %s\n\n", cyanB("→"), white("正在解析节点..."))
@@ -119,15 +206,99 @@ func runTest(cmd *cobra.Command, args []string) {
     fmt.Printf("  %s %s\n", greenB("✓"), whiteB(fmt.Sprintf("发现 %d 个节点", len(nodes))))
     if verbose {
         fmt.Printf("    %s\n", gray(fmt.Sprintf("并发数: %d, 超时: %d秒", normalizedConcurrency, normalizedTimeout)))
+        if runDeadline > 0 || nodeBudget > 0 {
+            fmt.Printf("    %s\n", gray(fmt.Sprintf("总时限: %s, 节点时限: %s", formatLimit(runDeadline), formatLimit(nodeBudget))))
+        }
+        fmt.Printf("    %s\n", gray(fmt.Sprintf("探测: %s %s", strings.ToUpper(probeMethod), probeURL)))
+        if samples > 1 {
+            fmt.Printf("    %s\n", gray(fmt.Sprintf("采样: %d次, 间隔: %s, 排序: %s", samples, sampleInterval, sortStat)))
+        }
+        if speedTest {
+            fmt.Printf("    %s\n", gray(fmt.Sprintf("下载测速: %s, 时长: %d秒, 并发数: %d", speedURL, speedTime, speedConcurrency)))
+        }
+        if uploadTest {
+            fmt.Printf("    %s\n", gray(fmt.Sprintf("上传测速: %s, 数据量: %dMB, 并发数: %d", uploadURL, uploadSize, speedConcurrency)))
+        }
     }
     fmt.Println()
 
     // 3. 并发测试
     fmt.Printf("  %s %s\n\n", cyanB("→"), white("开始并发测试..."))
-    results := tester.TestNodes(nodes, normalizedConcurrency, normalizedTimeout)
+    results := tester.TestNodes(ctx, nodes, normalizedConcurrency, normalizedTimeout, opts)
+    switch {
+    case errors.Is(ctx.Err(), context.DeadlineExceeded):
+        fmt.Printf("  %s %s\n\n", yellow("⚠"), yellow(fmt.Sprintf("已到达总时限 %s，未完成测试的节点标记为跳过", runDeadline)))
+    case ctx.Err() != nil:
+        fmt.Printf("  %s %s\n\n", yellow("⚠"), yellow(fmt.Sprintf("测试已中断，显示已完成的 %d/%d 个节点", len(results), len(nodes))))
+    case speedTest || uploadTest:
+        fmt.Printf("  %s %s\n\n", cyanB("→"), white("开始测速..."))
+        tester.TestSpeed(ctx, results, speedConcurrency, normalizedTimeout, opts)
+        if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+            fmt.Printf("  %s %s\n\n", yellow("⚠"), yellow(fmt.Sprintf("已到达总时限 %s，未完成测速的节点标记为跳过", runDeadline)))
+        } else if ctx.Err() != nil {
+            fmt.Printf("  %s %s\n\n", yellow("⚠"), yellow("测速已中断，未完成测速的节点不显示速度"))
+        }
+    }
 
     // 4. 显示结果
     display.ShowResults(results, verbose)
+
+    // 被中断时结果不完整，以 130 (128+SIGINT) 退出，便于脚本区分；到达总时限属于预期的截止，仍以 0 退出
+    if errors.Is(ctx.Err(), context.Canceled) {
+        os.Exit(130)
+    }
+}
+
+// downloadSubscription 从订阅链接下载内容
+func downloadSubscription(ctx context.Context) (string, error) {
+    if verbose {
+        fmt.Printf("  %s %s\n", cyanB("→"), white("正在从 URL 下载订阅..."))
+        fmt.Printf("    %s\n\n", gray(subscriptionURL))
+    } else {
+        fmt.Printf("  %s %s\n\n", cyanB("→"), white("正在从 URL 下载订阅..."))
+    }
+    
+    content, err := fetcher.FetchSubscription(ctx, subscriptionURL, userAgent)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("下载订阅失败: %v", err)))
+        return "", err
+    }
+
+    if verbose {
+        fmt.Printf("  %s %s\n", greenB("✓"), white(fmt.Sprintf("下载成功，内容长度: %d 字节", len(content))))
+        preview := content
+        if len(preview) > 100 {
+            preview = preview[:100] + "..."
+        }
+        fmt.Printf("    %s\n\n", gray(fmt.Sprintf("内容预览: %s", preview)))
+    } else {
+        fmt.Printf("  %s %s\n\n", greenB("✓"), white("下载成功"))
+    }
+
+    return content, nil
+}
+
+// loadFile 从本地文件读取订阅内容
+func loadFile() (string, error) {
+    fmt.Printf("  %s %s\n", cyanB("→"), white("正在读取本地文件..."))
+    if verbose {
+        fmt.Printf("    %s\n", gray(configFile))
+    }
+    fmt.Println()
+
+    content, err := fetcher.ReadSubscriptionFile(configFile)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "  %s %s\n", redB("✗"), red(fmt.Sprintf("读取文件失败: %v", err)))
+        return "", err
+    }
+
+    if verbose {
+        fmt.Printf("  %s %s\n\n", greenB("✓"), white(fmt.Sprintf("读取成功，内容长度: %d 字节", len(content))))
+    } else {
+        fmt.Printf("  %s %s\n\n", greenB("✓"), white("读取成功"))
+    }
+
+    return content, nil
 }
 
 // printBanner 打印欢迎横幅
@@ -152,3 +323,11 @@ func min(a, b int) int {
     }
     return b
 }
+
+// formatLimit 格式化时限参数，0 表示不限
+func formatLimit(d time.Duration) string {
+    if d <= 0 {
+        return "不限"
+    }
+    return d.String()
+}
@@ -2,9 +2,12 @@ package display
 
 import (
     "fmt"
+    "math"
+    "proxy-tester/internal/parser"
     "proxy-tester/internal/tester"
     "sort"
     "strings"
+    "time"
 
     "github.com/fatih/color"
     "github.com/jedib0t/go-pretty/v6/table"
@@ -39,6 +42,14 @@ var (
     magentaB = color.New(color.FgMagenta, color.Bold).SprintFunc()
 )
 
+// sortStat 排序与显示真实延迟所用的统计量，由 SetSortStatistic 修改
+var sortStat = tester.StatMedian
+
+// SetSortStatistic 设置多次采样时结果排序所用的真实延迟统计量
+func SetSortStatistic(stat tester.Statistic) {
+    sortStat = stat
+}
+
 // ShowResults 显示测试结果
 func ShowResults(results []*tester.TestResult, verbose bool) {
     if len(results) == 0 {
@@ -46,7 +57,7 @@ func ShowResults(results []*tester.TestResult, verbose bool) {
         return
     }
 
-    // 按真实延迟排序 (低到高)
+    // 按真实延迟排序 (低到高)，多次采样时按所选统计量排序
     sortResults(results)
 
     // 统计数据
@@ -64,35 +75,57 @@ func ShowResults(results []*tester.TestResult, verbose bool) {
     printResultsTable(results)
     fmt.Println()
 
+    // 在 verbose 模式下显示分阶段耗时
+    if verbose {
+        printTimelineTable(results)
+        fmt.Println()
+    }
+
     // 打印延迟分布
-    if stats.Success > 0 {
+    if stats.Ranked() > 0 {
         printLatencyDistribution(results)
         fmt.Println()
     }
 
     // 打印最快节点
-    if stats.Success > 0 {
+    if stats.Ranked() > 0 {
         printTopNodes(results, 5)
         fmt.Println()
     }
 
+    // 打印下载速度排行
+    if stats.SpeedTested > 0 {
+        printTopSpeedNodes(results, 5)
+        fmt.Println()
+    }
+
     // 在 verbose 模式下显示失败节点的详细错误信息
     if verbose && stats.Failed > 0 {
         printFailedNodesDetail(results)
     }
+
+    // 在 verbose 模式下显示测速失败的原因
+    if verbose && stats.SpeedFailed > 0 {
+        printSpeedFailuresDetail(results)
+    }
 }
 
 // calculateStats 计算统计数据
 func calculateStats(results []*tester.TestResult) *Stats {
     stats := &Stats{
-        Total: len(results),
+        Total:    len(results),
+        Failures: make(map[tester.Failure]int),
     }
 
     var totalLatency int64
     var validLatencyCount int
     
     for _, r := range results {
-        if r.IsSuccess() {
+        if r.IsSuccess() && r.HandshakeOnly {
+            // 仅握手的延迟与真实延迟不可比，不计入延迟统计
+            stats.Success++
+            stats.HandshakeOnly++
+        } else if r.IsSuccess() {
             stats.Success++
             latency := r.ProxyLatency
             if latency <= 0 {
@@ -113,6 +146,14 @@ func calculateStats(results []*tester.TestResult) *Stats {
             }
         } else {
             stats.Failed++
+            stats.Failures[r.Failure]++
+        }
+
+        if r.DownloadTested() || r.UploadTested() {
+            stats.SpeedTested++
+            if r.DownloadError != nil || r.UploadError != nil {
+                stats.SpeedFailed++
+            }
         }
     }
 
@@ -127,15 +168,24 @@ func calculateStats(results []*tester.TestResult) *Stats {
 
 // Stats 统计信息
 type Stats struct {
-    Total        int
-    Success      int
-    Failed       int
-    SuccessRate  float64
-    AvgLatency   int
-    MinLatency   int
-    MaxLatency   int
-    FastestNode  *tester.TestResult
-    SlowestNode  *tester.TestResult
+    Total         int
+    Success       int
+    HandshakeOnly int // 成功节点中仅完成握手的数量
+    Failed        int
+    SuccessRate   float64
+    AvgLatency    int
+    MinLatency    int
+    MaxLatency    int
+    FastestNode   *tester.TestResult
+    SlowestNode   *tester.TestResult
+    SpeedTested   int
+    SpeedFailed   int
+    Failures      map[tester.Failure]int // 各失败原因的节点数
+}
+
+// Ranked 返回参与真实延迟排名的成功节点数，不含仅握手的节点
+func (s *Stats) Ranked() int {
+    return s.Success - s.HandshakeOnly
 }
 
 // printSummary 打印统计摘要
@@ -154,6 +204,9 @@ func printSummary(stats *Stats) {
     } else {
         fmt.Printf("  │  成功: %s", redB(successStr))
     }
+    if stats.HandshakeOnly > 0 {
+        fmt.Printf(" %s", gray(fmt.Sprintf("(其中仅握手 %d)", stats.HandshakeOnly)))
+    }
     
     // 失败
     if stats.Failed > 0 {
@@ -163,8 +216,19 @@ func printSummary(stats *Stats) {
     }
     fmt.Println()
 
+    // 失败原因分布
+    if stats.Failed > 0 {
+        parts := make([]string, 0, len(stats.Failures))
+        for _, failure := range tester.Failures {
+            if count := stats.Failures[failure]; count > 0 {
+                parts = append(parts, fmt.Sprintf("%s %s", failure, red(fmt.Sprintf("%d", count))))
+            }
+        }
+        fmt.Printf("\n  %s  失败原因: %s\n", "❌", strings.Join(parts, "  │  "))
+    }
+
     // 延迟统计
-    if stats.Success > 0 {
+    if stats.Ranked() > 0 {
         fmt.Printf("\n  %s  ", "⚡")
         fmt.Printf("平均延迟: %s", formatLatencyWithColor(stats.AvgLatency))
         fmt.Printf("  │  最快: %s", formatLatencyWithColor(stats.MinLatency))
@@ -187,21 +251,66 @@ func printResultsTable(results []*tester.TestResult) {
         {Number: 2, Align: text.AlignLeft, AlignHeader: text.AlignLeft},      // 节点名称
         {Number: 3, Align: text.AlignLeft, AlignHeader: text.AlignLeft},      // 服务器地址
         {Number: 4, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // 协议
-        {Number: 5, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // TCP延迟
+        {Number: 5, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // ICMP延迟 (启用时) / TCP延迟
         {Number: 6, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // 真实延迟
-        {Number: 7, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // 状态
+        {Number: 7, Align: text.AlignCenter, AlignHeader: text.AlignCenter},  // 抖动 / 丢包 / 下载速度 / 上传速度 / 状态
+        {Number: 8, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
+        {Number: 9, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
+        {Number: 10, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
+        {Number: 11, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
+        {Number: 12, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
     })
 
+    // 多次采样时真实延迟列显示所选统计量，并增加抖动与丢包列
+    sampled := false
+    for _, result := range results {
+        if result.ProxyStats.Sent > 1 {
+            sampled = true
+            break
+        }
+    }
+    proxyHeader := "真实延迟"
+    if sampled {
+        proxyHeader = fmt.Sprintf("真实延迟(%s)", statLabels[displayStat()])
+    }
+
+    // 有节点进行过对应测速时才显示下载/上传速度列
+    showDownload, showUpload := false, false
+    for _, result := range results {
+        showDownload = showDownload || result.DownloadTested()
+        showUpload = showUpload || result.UploadTested()
+    }
+
+    // 启用 ICMP 测试时显示 ICMP 延迟列
+    showICMP := false
+    for _, result := range results {
+        if result.ICMPStats.Sent > 0 {
+            showICMP = true
+            break
+        }
+    }
+
     // 设置表头 - 使用青色加粗
-    t.AppendHeader(table.Row{
+    header := table.Row{
         cyanB("序号"),
         cyanB("节点名称"),
         cyanB("服务器地址"),
         cyanB("协议"),
-        cyanB("TCP延迟"),
-        cyanB("真实延迟"),
-        cyanB("状态"),
-    })
+    }
+    if showICMP {
+        header = append(header, cyanB("ICMP延迟"))
+    }
+    header = append(header, cyanB("TCP延迟"), cyanB(proxyHeader))
+    if sampled {
+        header = append(header, cyanB("抖动"), cyanB("丢包"))
+    }
+    if showDownload {
+        header = append(header, cyanB("下载速度"))
+    }
+    if showUpload {
+        header = append(header, cyanB("上传速度"))
+    }
+    t.AppendHeader(append(header, cyanB("状态")))
 
     // 添加数据行
     for i, result := range results {
@@ -221,10 +330,14 @@ func printResultsTable(results []*tester.TestResult) {
         tcpLatencyStr := formatLatencySimple(result.TCPLatency)
 
         // 真实延迟
-        proxyLatencyStr := formatLatencySimple(result.ProxyLatency)
+        proxyLatency := displayLatency(result)
+        proxyLatencyStr := formatLatencySimple(proxyLatency)
 
         // 状态图标
-        statusIcon := formatStatusIcon(result.Status)
+        statusIcon := formatStatusIcon(result.Failure)
+        if result.IsSuccess() && result.HandshakeOnly {
+            statusIcon = greenB("✓") + gray(" 仅握手")
+        }
 
         // 根据状态着色
         if result.IsSuccess() {
@@ -236,7 +349,7 @@ func printResultsTable(results []*tester.TestResult) {
 
             name = colorizeByLatency(name, latency)
             tcpLatencyStr = colorizeByLatency(tcpLatencyStr, result.TCPLatency)
-            proxyLatencyStr = colorizeByLatency(proxyLatencyStr, result.ProxyLatency)
+            proxyLatencyStr = colorizeByLatency(proxyLatencyStr, proxyLatency)
         } else {
             // 失败节点 - 全部灰色
             name = gray(name)
@@ -246,21 +359,110 @@ func printResultsTable(results []*tester.TestResult) {
         }
 
         // 添加行
-        t.AppendRow(table.Row{
+        row := table.Row{
             whiteB(fmt.Sprintf("%d", i+1)),
             name,
             white(address),
             protocolStr,
-            tcpLatencyStr,
-            proxyLatencyStr,
-            statusIcon,
-        })
+        }
+        if showICMP {
+            // 失败节点也保留 ICMP 着色，便于区分主机不可达与端口被过滤
+            row = append(row, colorizeByLatency(formatLatencySimple(result.ICMPLatency), result.ICMPLatency))
+        }
+        row = append(row, tcpLatencyStr, proxyLatencyStr)
+        if sampled {
+            row = append(row, formatJitter(result.ProxyStats), formatLoss(result.ProxyStats))
+        }
+        if showDownload {
+            row = append(row, formatSpeedWithColor(result.DownloadSpeed))
+        }
+        if showUpload {
+            row = append(row, formatSpeedWithColor(result.UploadSpeed))
+        }
+        t.AppendRow(append(row, statusIcon))
     }
 
     // 渲染表格
     fmt.Println(t.Render())
 }
 
+// printTimelineTable 打印真实连接测试的分阶段耗时
+// 每行耗时最长的阶段高亮显示，失败节点在出错时未完成的阶段标记 ✗
+func printTimelineTable(results []*tester.TestResult) {
+    fmt.Printf("  %s\n\n", cyanB("⏱  分阶段耗时"))
+
+    t := table.NewWriter()
+    t.SetStyle(table.StyleRounded)
+
+    header := table.Row{cyanB("序号"), cyanB("节点名称")}
+    configs := []table.ColumnConfig{
+        {Number: 1, Align: text.AlignCenter, AlignHeader: text.AlignCenter},
+        {Number: 2, Align: text.AlignLeft, AlignHeader: text.AlignLeft},
+    }
+    for i, stage := range tester.TimedStages {
+        header = append(header, cyanB(stage.String()))
+        configs = append(configs, table.ColumnConfig{Number: i + 3, Align: text.AlignRight, AlignHeader: text.AlignCenter})
+    }
+    header = append(header, cyanB("总计"))
+    configs = append(configs, table.ColumnConfig{Number: len(tester.TimedStages) + 3, Align: text.AlignRight, AlignHeader: text.AlignCenter})
+    t.AppendHeader(header)
+    t.SetColumnConfigs(configs)
+
+    for i, result := range results {
+        name := result.Node.Name
+        if name == "" {
+            name = "未命名"
+        }
+        row := table.Row{whiteB(fmt.Sprintf("%d", i+1)), truncateString(name, 30)}
+
+        // 找出耗时最长的阶段，以及失败时从出错阶段起第一个未完成的阶段
+        // 超出时限而跳过的节点并未在某个阶段出错，不标记失败阶段
+        var slowest, failed tester.Stage
+        var slowestDuration time.Duration
+        markFailed := !result.IsSuccess() && result.Failure != tester.FailureDeadline
+        for _, stage := range tester.TimedStages {
+            d, ok := result.Timeline.Duration(stage)
+            if ok && d > slowestDuration {
+                slowest, slowestDuration = stage, d
+            }
+            if !ok && markFailed && failed == tester.StageUnknown && stage >= result.Failure.Stage() {
+                failed = stage
+            }
+        }
+
+        for _, stage := range tester.TimedStages {
+            d, ok := result.Timeline.Duration(stage)
+            switch {
+            case ok && stage == slowest && result.Timeline.Recorded():
+                row = append(row, yellowB(formatPhase(d)))
+            case ok:
+                row = append(row, formatPhase(d))
+            case stage == failed:
+                row = append(row, red("✗"))
+            default:
+                row = append(row, gray("-"))
+            }
+        }
+
+        if result.Timeline.Recorded() {
+            row = append(row, whiteB(formatPhase(result.Timeline.Total())))
+        } else {
+            row = append(row, gray("-"))
+        }
+        t.AppendRow(row)
+    }
+
+    fmt.Println(t.Render())
+}
+
+// formatPhase 格式化单个阶段的耗时
+func formatPhase(d time.Duration) string {
+    if d < time.Millisecond {
+        return "<1ms"
+    }
+    return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
 // printSeparator 打印分隔线
 func printSeparator(char string) {
     // 计算总宽度: 序号(6) + 名称(37) + 地址(24) + 协议(10) + TCP(11) + 真实(12) = 100
@@ -269,48 +471,31 @@ func printSeparator(char string) {
 }
 
 // formatProtocol 格式化协议类型（旧版本，兼容保留）
-func formatProtocol(proxyType interface{}) string {
-    protocolStr := fmt.Sprintf("%v", proxyType)
-    switch protocolStr {
-    case "vless":
-        return centerString(magenta("VLESS"), 8)
-    case "vmess":
-        return centerString(cyan("VMess"), 8)
-    case "ss":
-        return centerString(yellow("SS"), 8)
-    default:
-        return centerString(gray("Unknown"), 8)
-    }
+func formatProtocol(proxyType parser.ProxyType) string {
+    return centerString(formatProtocolSimple(proxyType), 8)
 }
 
 // formatProtocolSimple 格式化协议类型（简化版，用于表格库）
-func formatProtocolSimple(proxyType interface{}) string {
-    protocolStr := fmt.Sprintf("%v", proxyType)
-    switch protocolStr {
-    case "vless":
-        return magenta("VLESS")
-    case "vmess":
-        return cyan("VMess")
-    case "ss":
-        return yellow("SS")
-    default:
+// 名称与颜色由已注册的协议提供
+func formatProtocolSimple(proxyType parser.ProxyType) string {
+    protocol, ok := parser.LookupProtocol(proxyType)
+    if !ok {
         return gray("Unknown")
     }
+    return protocol.Color().Sprint(protocol.DisplayName())
 }
 
-// formatStatusIcon 格式化状态图标
-func formatStatusIcon(status string) string {
-    switch status {
-    case "成功":
+// formatStatusIcon 格式化状态图标，失败时附带失败原因
+func formatStatusIcon(failure tester.Failure) string {
+    switch {
+    case failure == tester.FailureNone:
         return greenB("✓")
-    case "超时":
-        return yellow("⏱")
-    case "失败":
-        return red("✗")
-    case "端口可达但连接失败":
-        return yellow("⚠")
+    case failure.IsTimeout():
+        return yellow("⏱ " + failure.String())
+    case failure == tester.FailureUnknown:
+        return gray("? " + failure.String())
     default:
-        return gray("?")
+        return red("✗ " + failure.String())
     }
 }
 
@@ -351,6 +536,48 @@ func colorizeByLatency(text string, latency int) string {
     }
 }
 
+// formatJitter 格式化抖动 (延迟标准差)
+func formatJitter(stats tester.LatencyStats) string {
+    if stats.Success == 0 {
+        return gray("-")
+    }
+    jitterStr := fmt.Sprintf("%.1fms", stats.Jitter)
+    if stats.Jitter < 10 {
+        return greenB(jitterStr)
+    } else if stats.Jitter < 30 {
+        return yellow(jitterStr)
+    } else {
+        return red(jitterStr)
+    }
+}
+
+// formatLoss 格式化采样失败比例，有失败时着色提示
+func formatLoss(stats tester.LatencyStats) string {
+    lossStr := fmt.Sprintf("%.0f%%", stats.Loss()*100)
+    if stats.Success == stats.Sent {
+        return green(lossStr)
+    } else if stats.Success > 0 {
+        return yellow(lossStr)
+    } else {
+        return red(lossStr)
+    }
+}
+
+// formatSpeedWithColor 格式化下载速度并根据值着色
+func formatSpeedWithColor(mbps float64) string {
+    if mbps < 0 {
+        return gray("-")
+    }
+    speedStr := fmt.Sprintf("%.1fMbps", mbps)
+    if mbps >= 50 {
+        return greenB(speedStr)
+    } else if mbps >= 10 {
+        return yellow(speedStr)
+    } else {
+        return red(speedStr)
+    }
+}
+
 // colorizeLatencyValue 根据延迟值着色延迟数值
 func colorizeLatencyValue(text string, latency int) string {
     if latency < 0 {
@@ -383,7 +610,7 @@ func printLatencyDistribution(results []*tester.TestResult) {
     successfulCount := 0
     
     for _, r := range results {
-        if !r.IsSuccess() {
+        if !r.IsSuccess() || r.HandshakeOnly {
             continue
         }
         latency := r.ProxyLatency
@@ -439,11 +666,16 @@ func printLatencyDistribution(results []*tester.TestResult) {
 
 // printTopNodes 打印最快的节点
 func printTopNodes(results []*tester.TestResult, topN int) {
-    fmt.Printf("  %s\n\n", cyanB("🏆 最快节点 TOP 5"))
+    if sortStat == tester.StatJitter {
+        fmt.Printf("  %s\n\n", cyanB("🏆 最稳定节点 TOP 5"))
+    } else {
+        fmt.Printf("  %s\n\n", cyanB("🏆 最快节点 TOP 5"))
+    }
     
+    // 仅握手的节点不参与排名
     successResults := make([]*tester.TestResult, 0)
     for _, r := range results {
-        if r.IsSuccess() {
+        if r.IsSuccess() && !r.HandshakeOnly {
             successResults = append(successResults, r)
         }
     }
@@ -457,7 +689,7 @@ func printTopNodes(results []*tester.TestResult, topN int) {
     }
     
     for i, r := range successResults {
-        latency := r.ProxyLatency
+        latency := displayLatency(r)
         if latency <= 0 {
             latency = r.TCPLatency
         }
@@ -488,24 +720,110 @@ func printTopNodes(results []*tester.TestResult, topN int) {
     }
 }
 
-// printFailedNodesDetail 打印失败节点详细信息
+// printTopSpeedNodes 打印下载速度最快的节点
+func printTopSpeedNodes(results []*tester.TestResult, topN int) {
+    speedResults := make([]*tester.TestResult, 0)
+    for _, r := range results {
+        if r.DownloadSpeed >= 0 {
+            speedResults = append(speedResults, r)
+        }
+    }
+    
+    if len(speedResults) == 0 {
+        return
+    }
+    
+    fmt.Printf("  %s\n\n", cyanB(fmt.Sprintf("🚀 下载速度 TOP %d", topN)))
+    
+    sort.SliceStable(speedResults, func(i, j int) bool {
+        return speedResults[i].DownloadSpeed > speedResults[j].DownloadSpeed
+    })
+    if len(speedResults) > topN {
+        speedResults = speedResults[:topN]
+    }
+    
+    for i, r := range speedResults {
+        medal := ""
+        switch i {
+        case 0:
+            medal = "🥇"
+        case 1:
+            medal = "🥈"
+        case 2:
+            medal = "🥉"
+        default:
+            medal = fmt.Sprintf("%d.", i+1)
+        }
+        
+        name := r.Node.Name
+        if name == "" {
+            name = "未命名"
+        }
+        name = truncateString(name, 40)
+        
+        fmt.Printf("  %s  %-42s %s  %s\n", 
+            medal,
+            whiteB(name),
+            formatSpeedWithColor(r.DownloadSpeed),
+            gray(r.Node.Address()))
+    }
+}
+
+// printSpeedFailuresDetail 打印测速失败节点的错误信息
+func printSpeedFailuresDetail(results []*tester.TestResult) {
+    fmt.Printf("  %s\n\n", yellowB("⚠️  测速失败节点"))
+    printSeparator("─")
+    
+    for _, result := range results {
+        if result.DownloadError == nil && result.UploadError == nil {
+            continue
+        }
+        name := result.Node.Name
+        if name == "" {
+            name = "未命名"
+        }
+        
+        fmt.Printf("  %s %s\n", yellow("▸"), whiteB(name))
+        if result.DownloadError != nil {
+            fmt.Printf("    下载: %s %s\n", formatStatusIcon(result.DownloadFailure()), red(result.DownloadError))
+        }
+        if result.UploadError != nil {
+            fmt.Printf("    上传: %s %s\n", formatStatusIcon(result.UploadFailure()), red(result.UploadError))
+        }
+    }
+    fmt.Println()
+}
+
+// printFailedNodesDetail 打印失败节点详细信息，按失败原因分组
 func printFailedNodesDetail(results []*tester.TestResult) {
-    failedCount := 0
+    groups := make(map[tester.Failure][]*tester.TestResult)
     for _, r := range results {
         if !r.IsSuccess() {
-            failedCount++
+            groups[r.Failure] = append(groups[r.Failure], r)
         }
     }
     
-    if failedCount == 0 {
+    if len(groups) == 0 {
         return
     }
     
     fmt.Printf("  %s\n\n", redB("❌ 失败节点详细信息"))
     printSeparator("─")
     
-    for i, result := range results {
-        if !result.IsSuccess() {
+    for _, failure := range tester.Failures {
+        group := groups[failure]
+        if len(group) == 0 {
+            continue
+        }
+        
+        // 无法确定阶段 (未知错误、超出时限跳过) 时不显示阶段
+        header := redB(fmt.Sprintf("%s (%d)", failure, len(group)))
+        if failure.Stage() != tester.StageUnknown {
+            header += " " + gray(fmt.Sprintf("阶段: %s", failure.Stage()))
+        }
+        fmt.Printf("  %s\n\n", header)
+        
+        for _, result := range group {
             name := result.Node.Name
             if name == "" {
                 name = "未命名"
@@ -514,40 +832,32 @@ func printFailedNodesDetail(results []*tester.TestResult) {
             fmt.Printf("  %s %s\n", red("▸"), whiteB(name))
             fmt.Printf("    地址: %s\n", gray(result.Node.Address()))
             fmt.Printf("    协议: %s\n", gray(fmt.Sprintf("%v", result.Node.Type)))
-            if result.Error != "" {
-                fmt.Printf("    错误: %s\n", red(result.Error))
+            if result.ICMPStats.Sent > 0 {
+                if result.ICMPLatency >= 0 {
+                    fmt.Printf("    ICMP: %s\n", green(fmt.Sprintf("%dms (主机在线)", result.ICMPLatency)))
+                } else {
+                    fmt.Printf("    ICMP: %s\n", yellow("无响应 (主机不可达或屏蔽了 ICMP)"))
+                }
             }
-            if i < len(results)-1 {
-                fmt.Println()
+            if result.Err != nil {
+                fmt.Printf("    错误: %s\n", red(result.Err.Error()))
             }
+            fmt.Println()
         }
     }
-    fmt.Println()
 }
 
 // sortResults 按真实延迟排序
+// 仅握手的成功节点排在经探测地址验证的节点之后，失败节点排在最后
 func sortResults(results []*tester.TestResult) {
     sort.SliceStable(results, func(i, j int) bool {
-        // 成功的排在前面
-        if results[i].IsSuccess() && !results[j].IsSuccess() {
-            return true
-        }
-        if !results[i].IsSuccess() && results[j].IsSuccess() {
-            return false
+        if rank(results[i]) != rank(results[j]) {
+            return rank(results[i]) < rank(results[j])
         }
 
         // 都成功时，按真实延迟排序
         if results[i].IsSuccess() && results[j].IsSuccess() {
-            // 优先使用ProxyLatency
-            latencyI := results[i].ProxyLatency
-            if latencyI <= 0 {
-                latencyI = results[i].TCPLatency
-            }
-            latencyJ := results[j].ProxyLatency
-            if latencyJ <= 0 {
-                latencyJ = results[j].TCPLatency
-            }
-            return latencyI < latencyJ
+            return sortValue(results[i]) < sortValue(results[j])
         }
 
         // 都失败时保持原顺序
@@ -555,6 +865,56 @@ func sortResults(results []*tester.TestResult) {
     })
 }
 
+// rank 返回结果的排序分组: 真实延迟成功为 0，仅握手成功为 1，失败为 2
+func rank(r *tester.TestResult) int {
+    switch {
+    case !r.IsSuccess():
+        return 2
+    case r.HandshakeOnly:
+        return 1
+    default:
+        return 0
+    }
+}
+
+// sortValue 返回排序所用的延迟值
+// 有采样统计时使用所选统计量，否则优先使用 ProxyLatency
+func sortValue(r *tester.TestResult) float64 {
+    if r.ProxyStats.Success > 0 {
+        return r.ProxyStats.Value(sortStat)
+    }
+    latency := r.ProxyLatency
+    if latency <= 0 {
+        latency = r.TCPLatency
+    }
+    return float64(latency)
+}
+
+// statLabels 统计量在表头中的名称
+var statLabels = map[tester.Statistic]string{
+    tester.StatMin:    "最小",
+    tester.StatMedian: "中位",
+    tester.StatAvg:    "平均",
+    tester.StatP95:    "P95",
+    tester.StatMax:    "最大",
+}
+
+// displayStat 返回真实延迟列显示的统计量，按抖动排序时显示中位数
+func displayStat() tester.Statistic {
+    if sortStat == tester.StatJitter {
+        return tester.StatMedian
+    }
+    return sortStat
+}
+
+// displayLatency 返回真实延迟列显示的延迟值
+func displayLatency(r *tester.TestResult) int {
+    if r.ProxyStats.Success == 0 {
+        return r.ProxyLatency
+    }
+    return int(math.Round(r.ProxyStats.Value(displayStat())))
+}
+
 // truncateString 截断字符串
 func truncateString(s string, maxLen int) string {
     runes := []rune(s)
@@ -2,18 +2,25 @@ package fetcher
 
 import (
 "compress/gzip"
+"context"
 "crypto/tls"
 "encoding/base64"
 "fmt"
 "io"
 "net"
 "net/http"
+"os"
 "strings"
 "time"
 )
 
+// DefaultUserAgent 默认使用的浏览器 User-Agent，避免被拦截
+const DefaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
 // FetchSubscription 从URL下载订阅内容并解码
-func FetchSubscription(url string) (string, error) {
+// userAgent 为空时使用 DefaultUserAgent；多数机场在 Clash 类 User-Agent 下返回 YAML 配置
+// ctx 取消或超出截止时间时中止下载
+func FetchSubscription(ctx context.Context, url string, userAgent string) (string, error) {
 // 创建自定义的 HTTP 客户端（绕过系统代理）
 client := &http.Client{
 Timeout: 30 * time.Second,
@@ -35,13 +42,16 @@ TLSHandshakeTimeout: 30 * time.Second,
 }
 
 // 创建请求并设置 User-Agent
-req, err := http.NewRequest("GET", url, nil)
+req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 if err != nil {
 return "", fmt.Errorf("创建请求失败: %w", err)
 }
 
-// 设置常见的浏览器 User-Agent 以避免被拦截
-req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+// 设置 User-Agent，默认使用常见的浏览器 User-Agent 以避免被拦截
+if userAgent == "" {
+userAgent = DefaultUserAgent
+}
+req.Header.Set("User-Agent", userAgent)
 req.Header.Set("Accept", "*/*")
 req.Header.Set("Accept-Encoding", "gzip, deflate")
 req.Header.Set("Connection", "keep-alive")
@@ -76,6 +86,26 @@ if len(body) == 0 {
 return "", fmt.Errorf("订阅内容为空")
 }
 
+return decodeContent(body)
+}
+
+// ReadSubscriptionFile 从本地文件读取订阅内容并解码
+// 文件内容可以是 URI 列表、Clash YAML 或 sing-box/Xray JSON 配置
+func ReadSubscriptionFile(path string) (string, error) {
+body, err := os.ReadFile(path)
+if err != nil {
+return "", fmt.Errorf("读取文件失败: %w", err)
+}
+
+if len(body) == 0 {
+return "", fmt.Errorf("订阅内容为空")
+}
+
+return decodeContent(body)
+}
+
+// decodeContent 尝试对订阅内容做 Base64 解码，失败时按明文处理
+func decodeContent(body []byte) (string, error) {
 // 尝试Base64解码
 decoded, err := base64.StdEncoding.DecodeString(string(body))
 if err != nil {
@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// clashConfig Clash/Mihomo 配置文件中与节点相关的部分
+type clashConfig struct {
+	Proxies []yaml.Node `yaml:"proxies"`
+}
+
+// clashProxy Clash/Mihomo 配置中的单个节点
+type clashProxy struct {
+	Name           string                 `yaml:"name"`
+	Type           string                 `yaml:"type"`
+	Server         string                 `yaml:"server"`
+	Port           string                 `yaml:"port"`
+	Ports          string                 `yaml:"ports"`
+	UUID           string                 `yaml:"uuid"`
+	Password       string                 `yaml:"password"`
+	Cipher         string                 `yaml:"cipher"`
+	AlterID        int                    `yaml:"alterId"`
+	Plugin         string                 `yaml:"plugin"`
+	PluginOpts     map[string]interface{} `yaml:"plugin-opts"`
+	Network        string                 `yaml:"network"`
+	TLS            bool                   `yaml:"tls"`
+	SNI            string                 `yaml:"sni"`
+	ServerName     string                 `yaml:"servername"`
+	ALPN           []string               `yaml:"alpn"`
+	Fingerprint    string                 `yaml:"client-fingerprint"`
+	SkipCertVerify bool                   `yaml:"skip-cert-verify"`
+	Flow           string                 `yaml:"flow"`
+
+	WSOpts struct {
+		Path                string            `yaml:"path"`
+		Headers             map[string]string `yaml:"headers"`
+		MaxEarlyData        int               `yaml:"max-early-data"`
+		EarlyDataHeaderName string            `yaml:"early-data-header-name"`
+	} `yaml:"ws-opts"`
+	GRPCOpts struct {
+		ServiceName string `yaml:"grpc-service-name"`
+	} `yaml:"grpc-opts"`
+	H2Opts struct {
+		Host []string `yaml:"host"`
+		Path string   `yaml:"path"`
+	} `yaml:"h2-opts"`
+	HTTPOpts struct {
+		Path    []string            `yaml:"path"`
+		Headers map[string][]string `yaml:"headers"`
+	} `yaml:"http-opts"`
+	RealityOpts struct {
+		PublicKey string `yaml:"public-key"`
+		ShortID   string `yaml:"short-id"`
+	} `yaml:"reality-opts"`
+
+	// Hysteria2/TUIC
+	Obfs                 string `yaml:"obfs"`
+	ObfsPassword         string `yaml:"obfs-password"`
+	CongestionController string `yaml:"congestion-controller"`
+}
+
+// clashProxyTypes Clash 节点类型到内部协议类型的映射
+var clashProxyTypes = map[string]ProxyType{
+	"vless":     ProxyTypeVLESS,
+	"vmess":     ProxyTypeVMess,
+	"ss":        ProxyTypeShadowsocks,
+	"trojan":    ProxyTypeTrojan,
+	"hysteria2": ProxyTypeHysteria2,
+	"tuic":      ProxyTypeTUIC,
+}
+
+// isClashConfig 判断内容是否为包含 proxies 列表的 Clash/Mihomo YAML 配置
+func isClashConfig(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimRight(line, " \r"), "proxies:") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClashConfig 解析 Clash/Mihomo YAML 配置中的 proxies 列表
+func parseClashConfig(content string, verbose bool) ([]*Node, error) {
+	var config clashConfig
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		return nil, fmt.Errorf("解析 Clash 配置失败: %w", err)
+	}
+
+	if verbose {
+		fmt.Printf("    %s %s\n", color.CyanString("📋"), color.WhiteString(fmt.Sprintf("检测到 Clash 配置，共 %d 个节点", len(config.Proxies))))
+	}
+
+	var nodes []*Node
+	for i := range config.Proxies {
+		// 逐个解码，单个节点字段类型错误不影响其余节点
+		var proxy clashProxy
+		if err := config.Proxies[i].Decode(&proxy); err != nil {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过无法解析的节点: %s", strings.Join(strings.Fields(err.Error()), " ")))
+			}
+			continue
+		}
+
+		node := proxy.toNode()
+		if node == nil {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过不支持的类型: %s (%s)", proxy.Type, proxy.Name))
+			}
+			continue
+		}
+		if node.Type == ProxyTypeUnknown {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过缺少必要字段的节点: %s", proxy.Name))
+			}
+			continue
+		}
+
+		nodes = append(nodes, node)
+		if verbose {
+			logParsedNode(i+1, node)
+		}
+	}
+
+	if verbose {
+		logParseDone(len(nodes))
+	}
+
+	return nodes, nil
+}
+
+// toNode 将 Clash 节点转换为内部节点结构，不支持的类型返回 nil
+func (p *clashProxy) toNode() *Node {
+	proxyType, ok := clashProxyTypes[p.Type]
+	if !ok {
+		return nil
+	}
+
+	node := &Node{
+		Type:     proxyType,
+		Name:     p.Name,
+		Server:   p.Server,
+		Port:     p.Port,
+		UUID:     p.UUID,
+		Password: p.Password,
+		Method:   p.Cipher,
+		AlterID:  p.AlterID,
+		Network:  p.Network,
+		TLS:      p.TLS,
+		Flow:     p.Flow,
+	}
+
+	node.Security.SNI = p.SNI
+	if node.Security.SNI == "" {
+		node.Security.SNI = p.ServerName
+	}
+	node.Security.ALPN = p.ALPN
+	node.Security.Fingerprint = p.Fingerprint
+	node.Security.AllowInsecure = p.SkipCertVerify
+
+	switch proxyType {
+	case ProxyTypeShadowsocks:
+		node.Plugin, node.PluginOpts = p.sip003Plugin()
+	case ProxyTypeTrojan:
+		// Trojan 总是运行在 TLS 之上
+		node.TLS = true
+	case ProxyTypeHysteria2:
+		node.TLS = true
+		node.Network = "udp"
+		node.Obfs = p.Obfs
+		node.ObfsPassword = p.ObfsPassword
+		if node.Port == "" {
+			node.Port = firstPort(p.Ports)
+		}
+	case ProxyTypeTUIC:
+		node.TLS = true
+		node.Network = "udp"
+		node.Congestion = p.CongestionController
+	}
+
+	if p.RealityOpts.PublicKey != "" {
+		node.TLS = true
+		node.Security.Type = "reality"
+		node.Security.PublicKey = p.RealityOpts.PublicKey
+		node.Security.ShortID = p.RealityOpts.ShortID
+	} else if node.TLS {
+		node.Security.Type = "tls"
+	}
+
+	// 传输层参数
+	switch node.Network {
+	case "", "tcp":
+		node.Network = "tcp"
+	case "ws":
+		node.Transport.Path = p.WSOpts.Path
+		node.Transport.Host = lookupHeader(p.WSOpts.Headers, "Host")
+		node.Transport.MaxEarlyData = p.WSOpts.MaxEarlyData
+		node.Transport.EarlyDataHeader = p.WSOpts.EarlyDataHeaderName
+	case "grpc":
+		node.Transport.ServiceName = p.GRPCOpts.ServiceName
+	case "h2":
+		node.Transport.Path = p.H2Opts.Path
+		if len(p.H2Opts.Host) > 0 {
+			node.Transport.Host = p.H2Opts.Host[0]
+		}
+	case "http":
+		// Clash 的 http 网络类型即 TCP 上的 HTTP 伪装头
+		node.Network = "tcp"
+		node.Transport.HeaderType = "http"
+		if len(p.HTTPOpts.Path) > 0 {
+			node.Transport.Path = p.HTTPOpts.Path[0]
+		}
+		if hosts := lookupHeader(p.HTTPOpts.Headers, "Host"); len(hosts) > 0 {
+			node.Transport.Host = hosts[0]
+		}
+	}
+
+	// 验证必要字段
+	if node.Server == "" || node.Port == "" {
+		node.Type = ProxyTypeUnknown
+	}
+
+	return node
+}
+
+// lookupHeader 不区分大小写地查找请求头，Clash 配置中也常写作 host
+func lookupHeader[V any](headers map[string]V, name string) V {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	var zero V
+	return zero
+}
+
+// sip003Plugin 将 Clash 的 plugin/plugin-opts 转换为 SIP003 插件名称与参数
+func (p *clashProxy) sip003Plugin() (string, string) {
+	if p.Plugin == "" {
+		return "", ""
+	}
+
+	opt := func(key string) string {
+		if v, ok := p.PluginOpts[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+
+	var opts []string
+	switch p.Plugin {
+	case "obfs":
+		// Clash 中的 obfs 即 simple-obfs
+		if mode := opt("mode"); mode != "" {
+			opts = append(opts, "obfs="+mode)
+		}
+		if host := opt("host"); host != "" {
+			opts = append(opts, "obfs-host="+host)
+		}
+		return "obfs-local", strings.Join(opts, ";")
+	case "v2ray-plugin":
+		if mode := opt("mode"); mode != "" && mode != "websocket" {
+			opts = append(opts, "mode="+mode)
+		}
+		if opt("tls") == "true" {
+			opts = append(opts, "tls")
+		}
+		if host := opt("host"); host != "" {
+			opts = append(opts, "host="+host)
+		}
+		if path := opt("path"); path != "" {
+			opts = append(opts, "path="+path)
+		}
+		if mux := opt("mux"); mux == "false" {
+			opts = append(opts, "mux=0")
+		}
+		return p.Plugin, strings.Join(opts, ";")
+	default:
+		keys := make([]string, 0, len(p.PluginOpts))
+		for key := range p.PluginOpts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			opts = append(opts, key+"="+opt(key))
+		}
+		return p.Plugin, strings.Join(opts, ";")
+	}
+}
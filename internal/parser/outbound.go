@@ -0,0 +1,484 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// jsonDocument JSON 格式订阅/配置中与节点相关的部分
+// sing-box/Xray 配置使用 outbounds，SIP008 订阅使用 version + servers
+type jsonDocument struct {
+	Outbounds []json.RawMessage `json:"outbounds"`
+	Version   int               `json:"version"`
+	Servers   []json.RawMessage `json:"servers"`
+}
+
+// outboundKind 用于区分 sing-box (type 字段) 与 Xray (protocol 字段) 出站
+type outboundKind struct {
+	Type     string `json:"type"`
+	Protocol string `json:"protocol"`
+	Tag      string `json:"tag"`
+}
+
+// singboxOutbound sing-box 出站配置
+type singboxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	UUID       string `json:"uuid"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	AlterID    int    `json:"alter_id"`
+	Security   string `json:"security"`
+	Flow       string `json:"flow"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+
+	// Hysteria2/TUIC
+	Obfs *struct {
+		Type     string `json:"type"`
+		Password string `json:"password"`
+	} `json:"obfs"`
+	CongestionControl string `json:"congestion_control"`
+
+	TLS *struct {
+		Enabled    bool     `json:"enabled"`
+		ServerName string   `json:"server_name"`
+		Insecure   bool     `json:"insecure"`
+		ALPN       []string `json:"alpn"`
+		UTLS       *struct {
+			Fingerprint string `json:"fingerprint"`
+		} `json:"utls"`
+		Reality *struct {
+			Enabled   bool   `json:"enabled"`
+			PublicKey string `json:"public_key"`
+			ShortID   string `json:"short_id"`
+		} `json:"reality"`
+	} `json:"tls"`
+
+	Transport *struct {
+		Type        string            `json:"type"`
+		Path        string            `json:"path"`
+		Host        json.RawMessage   `json:"host"`
+		Headers     map[string]string `json:"headers"`
+		ServiceName string            `json:"service_name"`
+
+		MaxEarlyData        int    `json:"max_early_data"`
+		EarlyDataHeaderName string `json:"early_data_header_name"`
+	} `json:"transport"`
+}
+
+// xrayOutbound Xray/V2Ray 出站配置
+type xrayOutbound struct {
+	Protocol string `json:"protocol"`
+	Tag      string `json:"tag"`
+	Settings struct {
+		// VMess/VLESS
+		Vnext []struct {
+			Address string `json:"address"`
+			Port    int    `json:"port"`
+			Users   []struct {
+				ID       string `json:"id"`
+				Flow     string `json:"flow"`
+				AlterID  int    `json:"alterId"`
+				Security string `json:"security"`
+			} `json:"users"`
+		} `json:"vnext"`
+		// Trojan/Shadowsocks
+		Servers []struct {
+			Address  string `json:"address"`
+			Port     int    `json:"port"`
+			Password string `json:"password"`
+			Method   string `json:"method"`
+		} `json:"servers"`
+	} `json:"settings"`
+	StreamSettings struct {
+		Network     string `json:"network"`
+		Security    string `json:"security"`
+		TLSSettings struct {
+			ServerName    string   `json:"serverName"`
+			ALPN          []string `json:"alpn"`
+			Fingerprint   string   `json:"fingerprint"`
+			AllowInsecure bool     `json:"allowInsecure"`
+		} `json:"tlsSettings"`
+		RealitySettings struct {
+			ServerName  string `json:"serverName"`
+			Fingerprint string `json:"fingerprint"`
+			PublicKey   string `json:"publicKey"`
+			ShortID     string `json:"shortId"`
+			SpiderX     string `json:"spiderX"`
+		} `json:"realitySettings"`
+		TCPSettings struct {
+			Header struct {
+				Type    string `json:"type"`
+				Request struct {
+					Path    []string            `json:"path"`
+					Headers map[string][]string `json:"headers"`
+				} `json:"request"`
+			} `json:"header"`
+		} `json:"tcpSettings"`
+		WSSettings struct {
+			Path    string            `json:"path"`
+			Host    string            `json:"host"`
+			Headers map[string]string `json:"headers"`
+		} `json:"wsSettings"`
+		GRPCSettings struct {
+			ServiceName string `json:"serviceName"`
+		} `json:"grpcSettings"`
+		HTTPSettings struct {
+			Host []string `json:"host"`
+			Path string   `json:"path"`
+		} `json:"httpSettings"`
+		HTTPUpgradeSettings struct {
+			Path string `json:"path"`
+			Host string `json:"host"`
+		} `json:"httpupgradeSettings"`
+		XHTTPSettings struct {
+			Path string `json:"path"`
+			Host string `json:"host"`
+			Mode string `json:"mode"`
+		} `json:"xhttpSettings"`
+		SplitHTTPSettings struct {
+			Path string `json:"path"`
+			Host string `json:"host"`
+			Mode string `json:"mode"`
+		} `json:"splithttpSettings"`
+		KCPSettings struct {
+			Seed   string `json:"seed"`
+			Header struct {
+				Type string `json:"type"`
+			} `json:"header"`
+		} `json:"kcpSettings"`
+	} `json:"streamSettings"`
+}
+
+// singboxProxyTypes sing-box 出站类型到内部协议类型的映射
+var singboxProxyTypes = map[string]ProxyType{
+	"vless":       ProxyTypeVLESS,
+	"vmess":       ProxyTypeVMess,
+	"shadowsocks": ProxyTypeShadowsocks,
+	"trojan":      ProxyTypeTrojan,
+	"hysteria2":   ProxyTypeHysteria2,
+	"tuic":        ProxyTypeTUIC,
+}
+
+// xrayProxyTypes Xray 出站协议到内部协议类型的映射
+var xrayProxyTypes = map[string]ProxyType{
+	"vless":       ProxyTypeVLESS,
+	"vmess":       ProxyTypeVMess,
+	"shadowsocks": ProxyTypeShadowsocks,
+	"trojan":      ProxyTypeTrojan,
+}
+
+// isJSONDocument 判断内容是否为 JSON 文档
+func isJSONDocument(content string) bool {
+	content = strings.TrimSpace(content)
+	return (strings.HasPrefix(content, "{") || strings.HasPrefix(content, "[")) && json.Valid([]byte(content))
+}
+
+// parseJSONDocument 解析 JSON 格式的节点配置
+// 支持带 outbounds 字段的 sing-box/Xray 完整配置、直接由出站组成的数组以及 SIP008 订阅
+func parseJSONDocument(content string, verbose bool) ([]*Node, error) {
+	content = strings.TrimSpace(content)
+
+	var outbounds []json.RawMessage
+	if strings.HasPrefix(content, "[") {
+		if err := json.Unmarshal([]byte(content), &outbounds); err != nil {
+			return nil, fmt.Errorf("解析出站配置失败: %w", err)
+		}
+	} else {
+		var document jsonDocument
+		if err := json.Unmarshal([]byte(content), &document); err != nil {
+			return nil, fmt.Errorf("解析 JSON 配置失败: %w", err)
+		}
+		if document.Version > 0 && len(document.Outbounds) == 0 {
+			return parseSIP008(document.Servers, verbose)
+		}
+		outbounds = document.Outbounds
+	}
+
+	return parseOutbounds(outbounds, verbose)
+}
+
+// parseOutbounds 解析 sing-box/Xray 出站列表，跳过 direct/block/selector 等非代理出站
+func parseOutbounds(outbounds []json.RawMessage, verbose bool) ([]*Node, error) {
+	if verbose {
+		fmt.Printf("    %s %s\n", color.CyanString("📋"), color.WhiteString(fmt.Sprintf("检测到出站配置，共 %d 个出站", len(outbounds))))
+	}
+
+	var nodes []*Node
+	for i, raw := range outbounds {
+		var kind outboundKind
+		if err := json.Unmarshal(raw, &kind); err != nil {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过无法解析的出站: %v", err))
+			}
+			continue
+		}
+
+		var parsed []*Node
+		var err error
+		if kind.Protocol != "" {
+			parsed, err = parseXrayOutbound(raw)
+		} else {
+			parsed, err = parseSingboxOutbound(raw)
+		}
+
+		if err != nil {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过无法解析的出站: %s (%v)", kind.Tag, err))
+			}
+			continue
+		}
+		if len(parsed) == 0 {
+			if verbose {
+				outboundType := kind.Type
+				if outboundType == "" {
+					outboundType = kind.Protocol
+				}
+				logSkipped(i+1, fmt.Sprintf("跳过非代理出站: %s (%s)", outboundType, kind.Tag))
+			}
+			continue
+		}
+
+		for _, node := range parsed {
+			nodes = append(nodes, node)
+			if verbose {
+				logParsedNode(i+1, node)
+			}
+		}
+	}
+
+	if verbose {
+		logParseDone(len(nodes))
+	}
+
+	return nodes, nil
+}
+
+// parseSingboxOutbound 解析单个 sing-box 出站，非代理出站返回空列表
+func parseSingboxOutbound(raw json.RawMessage) ([]*Node, error) {
+	var outbound singboxOutbound
+	if err := json.Unmarshal(raw, &outbound); err != nil {
+		return nil, err
+	}
+
+	proxyType, ok := singboxProxyTypes[outbound.Type]
+	if !ok {
+		return nil, nil
+	}
+
+	node := &Node{
+		Type:       proxyType,
+		Name:       outbound.Tag,
+		Server:     outbound.Server,
+		Port:       strconv.Itoa(outbound.ServerPort),
+		UUID:       outbound.UUID,
+		Password:   outbound.Password,
+		Method:     outbound.Method,
+		AlterID:    outbound.AlterID,
+		Flow:       outbound.Flow,
+		Plugin:     outbound.Plugin,
+		PluginOpts: outbound.PluginOpts,
+		Network:    "tcp",
+	}
+	if proxyType == ProxyTypeVMess {
+		node.Method = outbound.Security
+	}
+
+	if tls := outbound.TLS; tls != nil && tls.Enabled {
+		node.TLS = true
+		node.Security.Type = "tls"
+		node.Security.SNI = tls.ServerName
+		node.Security.ALPN = tls.ALPN
+		node.Security.AllowInsecure = tls.Insecure
+		if tls.UTLS != nil {
+			node.Security.Fingerprint = tls.UTLS.Fingerprint
+		}
+		if tls.Reality != nil && tls.Reality.Enabled {
+			node.Security.Type = "reality"
+			node.Security.PublicKey = tls.Reality.PublicKey
+			node.Security.ShortID = tls.Reality.ShortID
+		}
+	}
+
+	if transport := outbound.Transport; transport != nil {
+		node.Network = transport.Type
+		node.Transport.Path = transport.Path
+		node.Transport.ServiceName = transport.ServiceName
+		node.Transport.Host = transport.Headers["Host"]
+		if node.Transport.Host == "" {
+			node.Transport.Host = firstJSONString(transport.Host)
+		}
+		node.Transport.MaxEarlyData = transport.MaxEarlyData
+		node.Transport.EarlyDataHeader = transport.EarlyDataHeaderName
+		// sing-box 的 http 传输即 HTTP/2
+		if node.Network == "http" {
+			node.Network = "h2"
+		}
+	}
+
+	switch proxyType {
+	case ProxyTypeHysteria2:
+		node.Network = "udp"
+		if outbound.Obfs != nil {
+			node.Obfs = outbound.Obfs.Type
+			node.ObfsPassword = outbound.Obfs.Password
+		}
+	case ProxyTypeTUIC:
+		node.Network = "udp"
+		node.Congestion = outbound.CongestionControl
+	}
+
+	if node.Server == "" || outbound.ServerPort == 0 {
+		return nil, fmt.Errorf("缺少服务器地址或端口")
+	}
+
+	return []*Node{node}, nil
+}
+
+// parseXrayOutbound 解析单个 Xray 出站，非代理出站返回空列表
+// vnext/servers 中的每个服务器都会生成一个节点
+func parseXrayOutbound(raw json.RawMessage) ([]*Node, error) {
+	var outbound xrayOutbound
+	if err := json.Unmarshal(raw, &outbound); err != nil {
+		return nil, err
+	}
+
+	proxyType, ok := xrayProxyTypes[outbound.Protocol]
+	if !ok {
+		return nil, nil
+	}
+
+	var nodes []*Node
+	for _, server := range outbound.Settings.Vnext {
+		node := &Node{
+			Type:   proxyType,
+			Server: server.Address,
+			Port:   strconv.Itoa(server.Port),
+		}
+		if len(server.Users) > 0 {
+			node.UUID = server.Users[0].ID
+			node.Flow = server.Users[0].Flow
+			node.AlterID = server.Users[0].AlterID
+			if proxyType == ProxyTypeVMess {
+				node.Method = server.Users[0].Security
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	for _, server := range outbound.Settings.Servers {
+		nodes = append(nodes, &Node{
+			Type:     proxyType,
+			Server:   server.Address,
+			Port:     strconv.Itoa(server.Port),
+			Password: server.Password,
+			Method:   server.Method,
+		})
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("缺少服务器配置")
+	}
+
+	for i, node := range nodes {
+		node.Name = outbound.Tag
+		if len(nodes) > 1 {
+			node.Name = fmt.Sprintf("%s-%d", outbound.Tag, i+1)
+		}
+		outbound.applyStreamSettings(node)
+	}
+
+	return nodes, nil
+}
+
+// applyStreamSettings 将 Xray streamSettings 中的传输层与安全层参数写入节点
+func (o *xrayOutbound) applyStreamSettings(node *Node) {
+	stream := &o.StreamSettings
+
+	node.Network = stream.Network
+	if node.Network == "" {
+		node.Network = "tcp"
+	}
+
+	switch stream.Security {
+	case "tls":
+		node.TLS = true
+		node.Security.Type = "tls"
+		node.Security.SNI = stream.TLSSettings.ServerName
+		node.Security.ALPN = stream.TLSSettings.ALPN
+		node.Security.Fingerprint = stream.TLSSettings.Fingerprint
+		node.Security.AllowInsecure = stream.TLSSettings.AllowInsecure
+	case "reality":
+		node.TLS = true
+		node.Security.Type = "reality"
+		node.Security.SNI = stream.RealitySettings.ServerName
+		node.Security.Fingerprint = stream.RealitySettings.Fingerprint
+		node.Security.PublicKey = stream.RealitySettings.PublicKey
+		node.Security.ShortID = stream.RealitySettings.ShortID
+		node.Security.SpiderX = stream.RealitySettings.SpiderX
+	}
+
+	switch node.Network {
+	case "tcp", "raw":
+		node.Network = "tcp"
+		header := stream.TCPSettings.Header
+		node.Transport.HeaderType = header.Type
+		if len(header.Request.Path) > 0 {
+			node.Transport.Path = header.Request.Path[0]
+		}
+		if hosts := header.Request.Headers["Host"]; len(hosts) > 0 {
+			node.Transport.Host = hosts[0]
+		}
+	case "ws":
+		node.Transport.Path = stream.WSSettings.Path
+		node.Transport.Host = stream.WSSettings.Host
+		if node.Transport.Host == "" {
+			node.Transport.Host = stream.WSSettings.Headers["Host"]
+		}
+	case "grpc":
+		node.Transport.ServiceName = stream.GRPCSettings.ServiceName
+	case "h2", "http":
+		node.Network = "h2"
+		node.Transport.Path = stream.HTTPSettings.Path
+		if len(stream.HTTPSettings.Host) > 0 {
+			node.Transport.Host = stream.HTTPSettings.Host[0]
+		}
+	case "httpupgrade":
+		node.Transport.Path = stream.HTTPUpgradeSettings.Path
+		node.Transport.Host = stream.HTTPUpgradeSettings.Host
+	case "xhttp":
+		node.Transport.Path = stream.XHTTPSettings.Path
+		node.Transport.Host = stream.XHTTPSettings.Host
+		node.Transport.Mode = stream.XHTTPSettings.Mode
+	case "splithttp":
+		node.Transport.Path = stream.SplitHTTPSettings.Path
+		node.Transport.Host = stream.SplitHTTPSettings.Host
+		node.Transport.Mode = stream.SplitHTTPSettings.Mode
+	case "kcp", "mkcp":
+		node.Network = "kcp"
+		node.Transport.HeaderType = stream.KCPSettings.Header.Type
+		node.Transport.Seed = stream.KCPSettings.Seed
+	}
+}
+
+// firstJSONString 读取字符串或字符串数组形式的 JSON 值，返回第一个字符串
+func firstJSONString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil && len(list) > 0 {
+		return list[0]
+	}
+	return ""
+}
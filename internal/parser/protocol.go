@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Protocol 代理协议扩展点
+// 实现该接口并调用 RegisterProtocol 即可接入新协议：分享链接按 Schemes 分派给 Parse，
+// 测试时调用 Probe，结果表格使用 DisplayName 与 Color 显示协议列
+type Protocol interface {
+	// Type 返回协议类型，同一类型只保留最后注册的实现
+	Type() ProxyType
+	// Schemes 返回该协议的分享链接 scheme (不含 "://")，如 hysteria2 与 hy2
+	Schemes() []string
+	// Parse 解析分享链接，无法解析时返回 Type 为 ProxyTypeUnknown 的节点
+	Parse(link string) *Node
+	// Serialize 将节点转换为分享链接
+	Serialize(node *Node) (string, error)
+	// Probe 经节点完成一次真实代理请求，返回延迟毫秒数；ctx 取消时应尽快中止并返回
+	Probe(ctx context.Context, node *Node, timeout time.Duration) (int, error)
+	// DisplayName 返回结果表格中显示的协议名称
+	DisplayName() string
+	// Color 返回显示协议名称使用的颜色
+	Color() *color.Color
+}
+
+// TunnelDialer 协议可选实现的接口，实现后即可经节点进行下载/上传测速
+type TunnelDialer interface {
+	// DialTunnel 经节点建立到 host:port 的 TCP 隧道，连接的读写超时为 timeout
+	// ctx 取消时应中止拨号，并关闭已返回的连接
+	DialTunnel(ctx context.Context, node *Node, host string, port int, timeout time.Duration) (net.Conn, error)
+}
+
+// HandshakeProber 协议可选实现的接口
+// Probe 只完成与节点的握手、不经节点请求探测地址时 HandshakeOnly 返回 true，
+// 此类结果不校验 --probe-url/--expect-status，显示时单独标记且不参与真实延迟排名
+type HandshakeProber interface {
+	HandshakeOnly() bool
+}
+
+var (
+	protocolsMu sync.RWMutex
+	protocols   = make(map[ProxyType]Protocol)
+	schemes     = make(map[string]Protocol)
+
+	// builtins parser 自带的内置协议实现，不随 RegisterProtocol 替换
+	builtins = make(map[ProxyType]Protocol)
+)
+
+// RegisterProtocol 注册协议实现，已注册的同类型协议及其 scheme 会被替换
+func RegisterProtocol(p Protocol) {
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+
+	if old, ok := protocols[p.Type()]; ok {
+		for _, scheme := range old.Schemes() {
+			if schemes[scheme] == old {
+				delete(schemes, scheme)
+			}
+		}
+	}
+	protocols[p.Type()] = p
+	for _, scheme := range p.Schemes() {
+		schemes[scheme] = p
+	}
+}
+
+// LookupProtocol 返回指定类型的协议实现
+func LookupProtocol(proxyType ProxyType) (Protocol, bool) {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	p, ok := protocols[proxyType]
+	return p, ok
+}
+
+// BuiltinProtocol 返回 parser 自带的指定类型协议实现，即使该类型已被其他实现替换
+func BuiltinProtocol(proxyType ProxyType) (Protocol, bool) {
+	p, ok := builtins[proxyType]
+	return p, ok
+}
+
+// lookupScheme 返回处理指定分享链接 scheme 的协议实现
+func lookupScheme(scheme string) (Protocol, bool) {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	p, ok := schemes[scheme]
+	return p, ok
+}
+
+// builtinProtocol 内置协议的解析、序列化与显示实现
+// 测试实现位于 tester 包，由其在初始化时包装后重新注册
+type builtinProtocol struct {
+	proxyType ProxyType
+	schemes   []string
+	name      string
+	color     *color.Color
+	parse     func(link string) *Node
+	serialize func(node *Node) (string, error)
+}
+
+func (p *builtinProtocol) Type() ProxyType                      { return p.proxyType }
+func (p *builtinProtocol) Schemes() []string                    { return p.schemes }
+func (p *builtinProtocol) Parse(link string) *Node              { return p.parse(link) }
+func (p *builtinProtocol) Serialize(node *Node) (string, error) { return p.serialize(node) }
+func (p *builtinProtocol) DisplayName() string                  { return p.name }
+func (p *builtinProtocol) Color() *color.Color                  { return p.color }
+
+func (p *builtinProtocol) Probe(context.Context, *Node, time.Duration) (int, error) {
+	return -1, fmt.Errorf("协议 %s 未提供测试实现", p.name)
+}
+
+func init() {
+	for _, p := range []*builtinProtocol{
+		{ProxyTypeVLESS, []string{"vless"}, "VLESS", color.New(color.FgMagenta), parseVLESS, serializeVLESS},
+		{ProxyTypeVMess, []string{"vmess"}, "VMess", color.New(color.FgCyan), parseVMess, serializeVMess},
+		{ProxyTypeShadowsocks, []string{"ss"}, "SS", color.New(color.FgYellow), parseShadowsocks, serializeShadowsocks},
+		{ProxyTypeTrojan, []string{"trojan"}, "Trojan", color.New(color.FgGreen), parseTrojan, serializeTrojan},
+		{ProxyTypeHysteria2, []string{"hysteria2", "hy2"}, "Hysteria2", color.New(color.FgMagenta, color.Bold), parseHysteria2, serializeHysteria2},
+		{ProxyTypeTUIC, []string{"tuic"}, "TUIC", color.New(color.FgCyan, color.Bold), parseTUIC, serializeTUIC},
+	} {
+		builtins[p.proxyType] = p
+		RegisterProtocol(p)
+	}
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/fatih/color"
+)
+
+// sip008Server SIP008 订阅中的单个 Shadowsocks 服务器
+// 参考: https://shadowsocks.org/doc/sip008.html
+type sip008Server struct {
+	ID         string `json:"id"`
+	Remarks    string `json:"remarks"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+	Plugin     string `json:"plugin"`
+	PluginOpts string `json:"plugin_opts"`
+}
+
+// parseSIP008 解析 SIP008 订阅中的 servers 列表
+func parseSIP008(servers []json.RawMessage, verbose bool) ([]*Node, error) {
+	if verbose {
+		fmt.Printf("    %s %s\n", color.CyanString("📋"), color.WhiteString(fmt.Sprintf("检测到 SIP008 订阅，共 %d 个节点", len(servers))))
+	}
+
+	var nodes []*Node
+	for i, raw := range servers {
+		var server sip008Server
+		if err := json.Unmarshal(raw, &server); err != nil {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过无法解析的节点: %v", err))
+			}
+			continue
+		}
+
+		if server.Server == "" || server.ServerPort == 0 || server.Method == "" {
+			if verbose {
+				logSkipped(i+1, fmt.Sprintf("跳过缺少必要字段的节点: %s", server.Remarks))
+			}
+			continue
+		}
+
+		node := &Node{
+			Type:       ProxyTypeShadowsocks,
+			Name:       server.Remarks,
+			Server:     server.Server,
+			Port:       strconv.Itoa(server.ServerPort),
+			Password:   server.Password,
+			Method:     server.Method,
+			Plugin:     server.Plugin,
+			PluginOpts: server.PluginOpts,
+			Network:    "tcp",
+		}
+
+		nodes = append(nodes, node)
+		if verbose {
+			logParsedNode(i+1, node)
+		}
+	}
+
+	if verbose {
+		logParseDone(len(nodes))
+	}
+
+	return nodes, nil
+}
@@ -1,30 +1,89 @@
 package parser
 
+import "net"
+
 // ProxyType 代理协议类型
 type ProxyType string
 
 const (
-	ProxyTypeVLESS      ProxyType = "vless"
-	ProxyTypeVMess      ProxyType = "vmess"
+	ProxyTypeVLESS       ProxyType = "vless"
+	ProxyTypeVMess       ProxyType = "vmess"
 	ProxyTypeShadowsocks ProxyType = "ss"
-	ProxyTypeUnknown    ProxyType = "unknown"
+	ProxyTypeTrojan      ProxyType = "trojan"
+	ProxyTypeHysteria2   ProxyType = "hysteria2"
+	ProxyTypeTUIC        ProxyType = "tuic"
+	ProxyTypeUnknown     ProxyType = "unknown"
 )
 
 // Node 代理节点信息
 type Node struct {
-	Type     ProxyType // 协议类型
-	Name     string    // 节点名称
-	Server   string    // 服务器地址
-	Port     string    // 端口
-	UUID     string    // UUID (VLESS/VMess)
-	Password string    // 密码 (Shadowsocks)
-	Method   string    // 加密方式 (Shadowsocks)
-	Network  string    // 传输协议 (tcp/ws/grpc等)
-	TLS      bool      // 是否启用TLS
-	Raw      string    // 原始链接
+	Type       ProxyType // 协议类型
+	Name       string    // 节点名称
+	Server     string    // 服务器地址
+	Port       string    // 端口
+	UUID       string    // UUID (VLESS/VMess)
+	Password   string    // 密码 (Shadowsocks/Trojan)
+	Method     string    // 加密方式 (Shadowsocks/VMess)
+	AlterID    int       // 额外 ID (VMess)，为 0 时使用 AEAD 认证
+	Plugin     string    // SIP003 插件名称 (Shadowsocks)
+	PluginOpts string    // SIP003 插件参数 (Shadowsocks)
+	Flow       string    // XTLS 流控 (VLESS，如 xtls-rprx-vision)
+	Network    string    // 传输协议 (tcp/ws/grpc等)
+	TLS        bool      // 是否启用TLS
+	Transport  Transport // 传输层参数
+	Security   Security  // TLS/REALITY 参数
+	Raw        string    // 原始链接
+
+	// QUIC 类协议 (Hysteria2/TUIC) 专用字段
+	Obfs         string // 混淆类型 (如 salamander)
+	ObfsPassword string // 混淆密码
+	Congestion   string // 拥塞控制算法 (如 bbr/cubic)
+}
+
+// Transport 传输层参数，具体含义取决于 Node.Network
+type Transport struct {
+	Path        string // WebSocket/HTTP/h2 路径
+	Host        string // Host 头或伪装域名
+	ServiceName string // gRPC serviceName
+	HeaderType  string // 伪装头类型 (tcp 的 http 伪装、kcp 的头部类型等)
+	Mode        string // XHTTP 上行模式: auto/packet-up/stream-up/stream-one
+	Seed        string // mKCP 混淆密码，为空时使用默认的简单校验
+
+	// WebSocket 早期数据：首个数据包随握手请求头发送，路径中的 ?ed= 参数同样生效
+	MaxEarlyData    int    // 早期数据最大字节数，0 表示不启用
+	EarlyDataHeader string // 承载早期数据的请求头，为空时使用 Sec-WebSocket-Protocol
+}
+
+// Security TLS/REALITY 参数
+type Security struct {
+	Type          string   // 安全层类型: none/tls/reality
+	SNI           string   // TLS SNI，为空时使用服务器地址
+	ALPN          []string // TLS ALPN 列表
+	Fingerprint   string   // uTLS 指纹 (chrome/firefox等)
+	AllowInsecure bool     // 是否允许不安全证书
+	PublicKey     string   // REALITY 公钥 (pbk)
+	ShortID       string   // REALITY short ID (sid)
+	SpiderX       string   // REALITY 爬虫初始路径 (spx)
 }
 
 // Address 返回完整的服务器地址
 func (n *Node) Address() string {
-	return n.Server + ":" + n.Port
+	return net.JoinHostPort(n.Server, n.Port)
+}
+
+// ServerName 返回 TLS 握手使用的 SNI
+// 未声明 SNI 时与 v2rayN 行为一致，优先使用传输层的 Host，最后回退为服务器地址
+func (n *Node) ServerName() string {
+	if n.Security.SNI != "" {
+		return n.Security.SNI
+	}
+	if n.Transport.Host != "" {
+		return n.Transport.Host
+	}
+	return n.Server
+}
+
+// IsUDP 判断节点是否运行在 UDP (QUIC) 之上
+func (n *Node) IsUDP() bool {
+	return n.Type == ProxyTypeHysteria2 || n.Type == ProxyTypeTUIC
 }
@@ -0,0 +1,227 @@
+package tester
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// Stage 节点测试所处的阶段
+type Stage int
+
+const (
+	StageUnknown   Stage = iota
+	StageConfig          // 解析节点配置
+	StageDNS             // 解析服务器地址
+	StageConnect         // 建立 TCP 连接
+	StageTLS             // TLS/REALITY/QUIC 握手
+	StageTransport       // 传输层握手 (WebSocket/gRPC/XHTTP 等)
+	StageHandshake       // 代理协议握手与认证
+	StageProbe           // 经隧道请求探测地址
+)
+
+var stageNames = map[Stage]string{
+	StageUnknown:   "未知",
+	StageConfig:    "配置",
+	StageDNS:       "DNS",
+	StageConnect:   "TCP",
+	StageTLS:       "TLS",
+	StageTransport: "传输层",
+	StageHandshake: "协议握手",
+	StageProbe:     "探测",
+}
+
+// String 返回阶段名称
+func (s Stage) String() string {
+	return stageNames[s]
+}
+
+// Failure 节点测试失败的原因，按阶段与原因分类
+type Failure int
+
+const (
+	FailureNone         Failure = iota // 测试成功
+	FailureConfig                      // 节点配置错误 (UUID、密钥格式等)
+	FailureUnsupported                 // 不支持的协议、传输方式或加密方式
+	FailureDNS                         // 服务器地址解析失败
+	FailureTCPRefused                  // TCP 连接被拒绝
+	FailureTCPTimeout                  // TCP 连接超时
+	FailureUnreachable                 // 网络或主机不可达等其他连接错误
+	FailureTLSHandshake                // TLS/QUIC 握手失败
+	FailureCertificate                 // 证书不匹配 (含 REALITY 认证失败)
+	FailureTransport                   // 传输层握手被拒绝 (路径、Host、serviceName 错误等)
+	FailureAuthRejected                // 代理协议认证被拒绝 (UUID、密码错误等)
+	FailureNoResponse                  // 隧道建立后等待响应超时
+	FailureProbeHTTP                   // 探测请求的 HTTP 错误 (状态码不符、响应无效等)
+	FailureUnknown                     // 无法分类的错误
+	FailureDeadline                    // 未在总时限或节点时限内完成测试，已跳过
+)
+
+// Failures 按阶段顺序列出的全部失败原因，用于分组统计
+var Failures = []Failure{
+	FailureConfig,
+	FailureUnsupported,
+	FailureDNS,
+	FailureTCPRefused,
+	FailureTCPTimeout,
+	FailureUnreachable,
+	FailureTLSHandshake,
+	FailureCertificate,
+	FailureTransport,
+	FailureAuthRejected,
+	FailureNoResponse,
+	FailureProbeHTTP,
+	FailureUnknown,
+	FailureDeadline,
+}
+
+var failureInfo = map[Failure]struct {
+	name  string
+	stage Stage
+}{
+	FailureNone:         {"成功", StageUnknown},
+	FailureConfig:       {"配置错误", StageConfig},
+	FailureUnsupported:  {"不支持", StageConfig},
+	FailureDNS:          {"DNS解析失败", StageDNS},
+	FailureTCPRefused:   {"连接被拒绝", StageConnect},
+	FailureTCPTimeout:   {"连接超时", StageConnect},
+	FailureUnreachable:  {"网络不可达", StageConnect},
+	FailureTLSHandshake: {"TLS握手失败", StageTLS},
+	FailureCertificate:  {"证书不匹配", StageTLS},
+	FailureTransport:    {"传输层握手失败", StageTransport},
+	FailureAuthRejected: {"认证被拒绝", StageHandshake},
+	FailureNoResponse:   {"响应超时", StageHandshake},
+	FailureProbeHTTP:    {"探测HTTP错误", StageProbe},
+	FailureUnknown:      {"未知错误", StageUnknown},
+	FailureDeadline:     {"跳过(时限)", StageUnknown},
+}
+
+// String 返回失败原因的名称
+func (f Failure) String() string {
+	return failureInfo[f].name
+}
+
+// Stage 返回失败发生的阶段
+func (f Failure) Stage() Stage {
+	return failureInfo[f].stage
+}
+
+// IsTimeout 判断失败是否由超时引起
+func (f Failure) IsTimeout() bool {
+	return f == FailureTCPTimeout || f == FailureNoResponse || f == FailureDeadline
+}
+
+// failureError 为错误标记失败原因，保留原始错误链
+type failureError struct {
+	failure Failure
+	err     error
+}
+
+func (e *failureError) Error() string { return e.err.Error() }
+func (e *failureError) Unwrap() error { return e.err }
+
+// atStage 标记 err 发生在 stage 阶段，并据此按原因分类
+// 错误链中已有标记时保持不变，因此最先 (最内层) 标记的阶段生效
+func atStage(stage Stage, err error) error {
+	if err == nil {
+		return nil
+	}
+	var tagged *failureError
+	if errors.As(err, &tagged) {
+		return err
+	}
+	return &failureError{failure: classify(stage, err), err: err}
+}
+
+// unsupported 将 err 标记为不支持的协议、传输方式或加密方式
+func unsupported(err error) error {
+	return &failureError{failure: FailureUnsupported, err: err}
+}
+
+// classifyError 返回错误对应的失败原因，err 为 nil 时返回 FailureNone
+func classifyError(err error) Failure {
+	if err == nil {
+		return FailureNone
+	}
+	var tagged *failureError
+	if errors.As(err, &tagged) {
+		return tagged.failure
+	}
+	// 未标记的拨号错误 (如第三方协议实现返回的错误) 视为连接阶段
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return classify(StageConnect, err)
+	}
+	return classify(StageUnknown, err)
+}
+
+// classify 根据发生阶段与错误原因确定失败分类
+func classify(stage Stage, err error) Failure {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return FailureDNS
+	}
+	if isCertificateError(err) {
+		return FailureCertificate
+	}
+
+	switch stage {
+	case StageConfig:
+		return FailureConfig
+	case StageDNS:
+		return FailureDNS
+	case StageConnect:
+		switch {
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return FailureTCPRefused
+		case isTimeout(err):
+			return FailureTCPTimeout
+		default:
+			return FailureUnreachable
+		}
+	case StageTLS:
+		return FailureTLSHandshake
+	case StageTransport:
+		return FailureTransport
+	case StageHandshake:
+		if isTimeout(err) {
+			return FailureNoResponse
+		}
+		return FailureAuthRejected
+	case StageProbe:
+		return FailureProbeHTTP
+	}
+	return FailureUnknown
+}
+
+// isTimeout 判断错误是否由超时引起
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// isCertificateError 判断错误是否由服务器证书校验失败引起
+func isCertificateError(err error) bool {
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var verifyErr *tls.CertificateVerificationError
+	return errors.Is(err, errRealityUnverified) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &verifyErr)
+}
+
+// isConnectionError 判断错误是否来自底层连接 (关闭、重置或超时)
+// 这类错误经隧道传递上来，无法确定是探测目标还是节点本身的问题
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.As(err, &netErr)
+}
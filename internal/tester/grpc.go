@@ -0,0 +1,162 @@
+package tester
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy-tester/internal/parser"
+	"strings"
+)
+
+// gunMaxMessage 单个 gRPC 消息的最大长度，超出视为协议错误
+const gunMaxMessage = 1 << 20
+
+// gunConn 基于 gRPC 双向流的 gun 隧道
+// 每次写入封装为一条 Hunk 消息: 压缩标志(1) + 长度(4) + protobuf{1: bytes data}
+type gunConn struct {
+	*h2Stream
+	reader  *bufio.Reader
+	pending []byte
+}
+
+// dialGRPC 在已建立的连接上打开 gun 隧道
+// serviceName 与服务器不一致时，服务器以 grpc-status 12 (Unimplemented) 拒绝该流
+func dialGRPC(conn net.Conn, node *parser.Node) (net.Conn, error) {
+	host := node.Transport.Host
+	if host == "" {
+		host = node.ServerName()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h2Scheme(node)+"://"+host+gunPath(node.Transport.ServiceName), nil)
+	if err != nil {
+		return nil, atStage(StageConfig, fmt.Errorf("无效的gRPC serviceName: %w", err))
+	}
+	req.Host = host
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("User-Agent", "grpc-go/1.60.0")
+	req.Header.Set("Te", "trailers")
+
+	stream, err := newH2Stream(conn, req, checkGRPCResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &gunConn{h2Stream: stream}, nil
+}
+
+// gunPath 根据 serviceName 生成请求路径
+// 与 Xray 一致，以 / 开头的 serviceName 为自定义路径: /服务路径/流名称|多路流名称
+func gunPath(serviceName string) string {
+	if !strings.HasPrefix(serviceName, "/") {
+		return "/" + url.PathEscape(serviceName) + "/Tun"
+	}
+
+	last := strings.LastIndex(serviceName, "/")
+	if last < 1 {
+		last = 1
+	}
+	parts := strings.Split(serviceName[1:last], "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	stream, _, _ := strings.Cut(serviceName[strings.LastIndex(serviceName, "/")+1:], "|")
+	return "/" + strings.Join(parts, "/") + "/" + url.PathEscape(stream)
+}
+
+// checkGRPCResponse 校验 gRPC 响应头
+// 服务器拒绝请求时通常直接返回只含 grpc-status 的响应 (Trailers-Only)
+func checkGRPCResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gRPC请求失败: HTTP %d", resp.StatusCode)
+	}
+	if status := resp.Header.Get("Grpc-Status"); status != "" && status != "0" {
+		return fmt.Errorf("gRPC请求被拒绝: status %s %s (serviceName错误或节点不可用)", status, resp.Header.Get("Grpc-Message"))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/grpc") {
+		return fmt.Errorf("gRPC响应类型错误: %s", resp.Header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// Write 将数据封装为一条 Hunk 消息发送
+func (c *gunConn) Write(p []byte) (int, error) {
+	hunk := binary.AppendUvarint([]byte{0x0a}, uint64(len(p)))
+
+	buf := make([]byte, 0, 5+len(hunk)+len(p))
+	buf = append(buf, 0x00)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(hunk)+len(p)))
+	buf = append(buf, hunk...)
+	buf = append(buf, p...)
+
+	if _, err := c.h2Stream.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read 逐条解析 Hunk 消息并返回其中的数据
+func (c *gunConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if err := c.nextMessage(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// nextMessage 读取下一条 gRPC 消息，流结束时检查 trailer 中的 grpc-status
+func (c *gunConn) nextMessage() error {
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.h2Stream)
+	}
+
+	var header [5]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		if err == io.EOF {
+			if status := c.response.resp.Trailer.Get("Grpc-Status"); status != "" && status != "0" {
+				return fmt.Errorf("gRPC流异常结束: status %s %s", status, c.response.resp.Trailer.Get("Grpc-Message"))
+			}
+		}
+		return err
+	}
+	if header[0] != 0 {
+		return fmt.Errorf("不支持压缩的gRPC消息")
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > gunMaxMessage {
+		return fmt.Errorf("gRPC消息过长: %d", length)
+	}
+
+	message := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, message); err != nil {
+		return err
+	}
+
+	// 解析 protobuf：只关心字段 1 (data)，其余字段跳过
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return fmt.Errorf("gRPC消息格式错误")
+		}
+		message = message[n:]
+		if tag&0x07 != 2 {
+			return fmt.Errorf("gRPC消息格式错误")
+		}
+		size, n := binary.Uvarint(message)
+		if n <= 0 || uint64(len(message)-n) < size {
+			return fmt.Errorf("gRPC消息格式错误")
+		}
+		field := message[n : n+int(size)]
+		message = message[n+int(size):]
+		if tag>>3 == 1 {
+			c.pending = append(c.pending, field...)
+		}
+	}
+	return nil
+}
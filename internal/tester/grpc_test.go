@@ -0,0 +1,107 @@
+package tester
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"strings"
+	"testing"
+)
+
+// gunServerConn 服务器端的 gun 隧道：解析客户端的 Hunk 消息，写入时封装为 Hunk 消息
+type gunServerConn struct {
+	h2ServerStream
+	reader  *bufio.Reader
+	pending []byte
+}
+
+func (c *gunServerConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		var header [5]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return 0, err
+		}
+		message := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(c.reader, message); err != nil {
+			return 0, err
+		}
+		// 客户端只发送字段 1 (data)
+		if len(message) == 0 || message[0] != 0x0a {
+			return 0, errors.New("无效的 Hunk 消息")
+		}
+		size, n := binary.Uvarint(message[1:])
+		if n <= 0 || uint64(len(message)-1-n) != size {
+			return 0, errors.New("无效的 Hunk 消息")
+		}
+		c.pending = message[1+n:]
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *gunServerConn) Write(p []byte) (int, error) {
+	hunk := binary.AppendUvarint([]byte{0x0a}, uint64(len(p)))
+	buf := binary.BigEndian.AppendUint32([]byte{0x00}, uint32(len(hunk)+len(p)))
+	buf = append(append(buf, hunk...), p...)
+	if _, err := c.h2ServerStream.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// grpcHandler 模拟 Xray 的 gRPC 入站：serviceName 不符时以 Trailers-Only 响应返回 grpc-status 12
+func grpcHandler(serviceName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.URL.Path != gunPath(serviceName) {
+			w.Header().Set("Grpc-Status", "12")
+			w.Header().Set("Grpc-Message", "unknown service")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		serveVLESS(&gunServerConn{
+			h2ServerStream: h2ServerStream{Reader: r.Body, w: w},
+			reader:         bufio.NewReader(r.Body),
+		}, testUUID)
+	})
+}
+
+func TestGRPCTransport(t *testing.T) {
+	port := startH2CServer(t, grpcHandler("proxy"))
+
+	tests := []struct {
+		name        string
+		serviceName string
+		uuid        string
+		want        Failure
+	}{
+		{"ok", "proxy", testUUID, FailureNone},
+		{"wrong-service-name", "other", testUUID, FailureTransport},
+		{"wrong-uuid", "proxy", "00000000-0000-0000-0000-000000000000", FailureAuthRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := probeNode(t, &parser.Node{
+				Name:      tt.name,
+				Type:      parser.ProxyTypeVLESS,
+				Server:    "127.0.0.1",
+				Port:      port,
+				UUID:      tt.uuid,
+				Network:   "grpc",
+				Transport: parser.Transport{ServiceName: tt.serviceName},
+			})
+			if got != tt.want {
+				t.Fatalf("失败原因为 %s (%v)，期望 %s", got, err, tt.want)
+			}
+			if tt.want == FailureTransport && !strings.Contains(err.Error(), "status 12") {
+				t.Errorf("错误 %q 不包含 grpc-status 12", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package tester
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// h2Stream 基于单个 HTTP/2 流的双向连接：写入的数据作为请求体发送，读取响应体
+// HTTP/2 (h2) 与 gRPC (gun) 传输都以此为基础
+type h2Stream struct {
+	net.Conn // 底层 TCP/TLS 连接，用于设置超时与获取地址
+
+	client   *http2.ClientConn
+	writer   *io.PipeWriter
+	response *h2Response
+	validate func(*http.Response) error
+
+	closeOnce sync.Once
+}
+
+// newH2Stream 在已建立的连接上发起 HTTP/2 请求，请求体保持打开以持续发送数据
+// validate 用于在首次读取时校验响应头
+func newH2Stream(conn net.Conn, req *http.Request, validate func(*http.Response) error) (*h2Stream, error) {
+	client, err := newH2Client(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, writer := io.Pipe()
+	req.Body = reader
+	req.ContentLength = -1
+
+	return &h2Stream{
+		Conn:     conn,
+		client:   client,
+		writer:   writer,
+		response: roundTripAsync(client, req),
+		validate: validate,
+	}, nil
+}
+
+// newH2Client 在已建立的连接上创建 HTTP/2 客户端连接 (TLS 上的 h2 或明文 h2c)
+func newH2Client(conn net.Conn) (*http2.ClientConn, error) {
+	client, err := (&http2.Transport{AllowHTTP: true}).NewClientConn(conn)
+	if err != nil {
+		return nil, fmt.Errorf("建立HTTP/2连接失败: %w", err)
+	}
+	return client, nil
+}
+
+// Write 将数据写入请求体
+func (s *h2Stream) Write(p []byte) (int, error) {
+	return s.writer.Write(p)
+}
+
+// Read 等待响应头并校验后读取响应体
+func (s *h2Stream) Read(p []byte) (int, error) {
+	resp, err := s.response.wait()
+	if err != nil {
+		return 0, err
+	}
+	if s.validate != nil {
+		err := atStage(StageTransport, s.validate(resp))
+		s.validate = nil
+		if err != nil {
+			s.response.err = err
+			return 0, err
+		}
+	}
+	return resp.Body.Read(p)
+}
+
+// Close 关闭请求体、响应体与底层连接
+func (s *h2Stream) Close() error {
+	s.closeOnce.Do(func() {
+		s.writer.Close()
+		s.response.close()
+		s.client.Close()
+	})
+	return s.Conn.Close()
+}
+
+// h2Response 在后台发起的 HTTP/2 请求
+// 部分服务器在收到首个数据后才返回响应头，因此不能同步等待
+type h2Response struct {
+	ready chan struct{}
+	resp  *http.Response
+	err   error
+}
+
+// roundTripAsync 在后台发送请求，响应头到达后可通过 wait 获取
+func roundTripAsync(client *http2.ClientConn, req *http.Request) *h2Response {
+	r := &h2Response{ready: make(chan struct{})}
+	go func() {
+		r.resp, r.err = client.RoundTrip(req)
+		close(r.ready)
+	}()
+	return r
+}
+
+// wait 等待响应头到达，超时由底层连接的 deadline 控制
+func (r *h2Response) wait() (*http.Response, error) {
+	<-r.ready
+	return r.resp, r.err
+}
+
+// close 关闭已到达的响应体
+func (r *h2Response) close() {
+	select {
+	case <-r.ready:
+		if r.resp != nil {
+			r.resp.Body.Close()
+		}
+	default:
+	}
+}
+
+// dialH2 在已建立的连接上打开 HTTP/2 (h2) 传输流
+// 与 Xray 一致，使用 PUT 请求，路径或 Host 与服务器配置不符时返回 404
+func dialH2(conn net.Conn, node *parser.Node) (net.Conn, error) {
+	host := node.Transport.Host
+	if host == "" {
+		host = node.ServerName()
+	}
+	path := node.Transport.Path
+	if path == "" {
+		path = "/"
+	}
+
+	req, err := http.NewRequest(http.MethodPut, h2Scheme(node)+"://"+host+path, nil)
+	if err != nil {
+		return nil, atStage(StageConfig, fmt.Errorf("无效的h2路径: %w", err))
+	}
+	req.Host = host
+
+	return newH2Stream(conn, req, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("h2请求失败: HTTP %d (路径或Host错误)", resp.StatusCode)
+		}
+		return nil
+	})
+}
+
+// h2Scheme 返回请求使用的协议：启用 TLS 时为 https，否则为 h2c 明文
+func h2Scheme(node *parser.Node) string {
+	if node.TLS {
+		return "https"
+	}
+	return "http"
+}
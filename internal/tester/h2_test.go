@@ -0,0 +1,72 @@
+package tester
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+// startH2CServer 启动本地明文 HTTP/2 (h2c) 测试服务器，返回监听端口
+func startH2CServer(t *testing.T, handler http.Handler) string {
+	server := &http2.Server{}
+	return startTCPServer(t, func(conn net.Conn) {
+		server.ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+	})
+}
+
+// h2ServerStream 服务器端的 HTTP/2 双向流：读取请求体，写入响应体并立即刷新
+type h2ServerStream struct {
+	io.Reader
+	w http.ResponseWriter
+}
+
+func (s *h2ServerStream) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.w.(http.Flusher).Flush()
+	return n, err
+}
+
+// h2Handler 模拟 Xray 的 h2 入站：路径不符时返回 404
+func h2Handler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path || r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		serveVLESS(&h2ServerStream{Reader: r.Body, w: w}, testUUID)
+	})
+}
+
+func TestH2Transport(t *testing.T) {
+	port := startH2CServer(t, h2Handler("/h2"))
+
+	tests := []struct {
+		name string
+		path string
+		uuid string
+		want Failure
+	}{
+		{"ok", "/h2", testUUID, FailureNone},
+		{"wrong-path", "/other", testUUID, FailureTransport},
+		{"wrong-uuid", "/h2", "00000000-0000-0000-0000-000000000000", FailureAuthRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectFailure(t, &parser.Node{
+				Name:      tt.name,
+				Type:      parser.ProxyTypeVLESS,
+				Server:    "127.0.0.1",
+				Port:      port,
+				UUID:      tt.uuid,
+				Network:   "h2",
+				Transport: parser.Transport{Path: tt.path},
+			}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package tester
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"strings"
+	"time"
+)
+
+// tcpTransport TCP 传输，可叠加 HTTP 伪装头 (headerType=http)
+type tcpTransport struct{}
+
+func (tcpTransport) dial(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	switch node.Transport.HeaderType {
+	case "", "none":
+		return dialNode(ctx, node, timeout, timeline)
+	case "http":
+		conn, err := dialNode(ctx, node, timeout, timeline)
+		if err != nil {
+			return nil, err
+		}
+		return newHTTPHeaderConn(conn, node), nil
+	}
+	return nil, unsupported(fmt.Errorf("暂不支持的TCP伪装类型: %s", node.Transport.HeaderType))
+}
+
+// httpHeaderConn TCP 的 HTTP 伪装：首次写入前发送 HTTP 请求头，首次读取时去除服务器的 HTTP 响应头
+// 请求头与首个数据包一起发送，不增加往返
+type httpHeaderConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func newHTTPHeaderConn(conn net.Conn, node *parser.Node) *httpHeaderConn {
+	// 路径与 Host 可以是逗号分隔的列表，使用第一个
+	path, _, _ := strings.Cut(node.Transport.Path, ",")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	host, _, _ := strings.Cut(node.Transport.Host, ",")
+	if host == "" {
+		host = node.ServerName()
+	}
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"User-Agent: Mozilla/5.0\r\n" +
+		"Accept-Encoding: gzip, deflate\r\n" +
+		"Connection: keep-alive\r\n" +
+		"Pragma: no-cache\r\n" +
+		"\r\n"
+	return &httpHeaderConn{Conn: &prefixConn{Conn: conn, prefix: []byte(request)}}
+}
+
+// Read 首次读取时校验并去除响应头
+// Xray 在关闭连接前返回错误响应：请求路径不符时为 404，其他错误 (如认证失败) 为 400；
+// 路径不符时服务器通常先读取并丢弃一段时间的数据，表现为等待响应超时
+func (c *httpHeaderConn) Read(p []byte) (int, error) {
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.Conn)
+		resp, err := http.ReadResponse(c.reader, nil)
+		if err != nil {
+			return 0, fmt.Errorf("读取HTTP伪装响应失败: %w", err)
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return 0, atStage(StageTransport, fmt.Errorf("HTTP伪装握手失败: HTTP %d (路径错误)", resp.StatusCode))
+		case resp.StatusCode/100 != 2:
+			return 0, atStage(StageHandshake, fmt.Errorf("服务器拒绝请求: HTTP %d", resp.StatusCode))
+		}
+	}
+	return c.reader.Read(p)
+}
@@ -0,0 +1,59 @@
+package tester
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"strings"
+)
+
+// httpUpgradeConn HTTPUpgrade 传输：完成 HTTP/1.1 Upgrade 后直接传输原始数据，不再分帧
+type httpUpgradeConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// dialHTTPUpgrade 在已建立的连接上完成 HTTPUpgrade 握手
+// 路径或 Host 与服务器配置不符时，Xray 直接关闭连接而不返回响应
+// 路径中的 ?ed= 会被去除并始终等待握手响应：服务器读取握手请求时可能丢弃紧随其后的数据
+func dialHTTPUpgrade(conn net.Conn, node *parser.Node) (net.Conn, error) {
+	host := node.Transport.Host
+	if host == "" {
+		host = node.ServerName()
+	}
+	path, _ := splitEarlyData(node.Transport.Path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"User-Agent: Mozilla/5.0\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Upgrade: websocket\r\n" +
+		"\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		return nil, fmt.Errorf("发送HTTPUpgrade握手失败: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, fmt.Errorf("读取HTTPUpgrade握手响应失败(路径或Host错误): %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		return nil, fmt.Errorf("HTTPUpgrade握手失败: HTTP %d (路径或Host错误)", resp.StatusCode)
+	}
+	return &httpUpgradeConn{Conn: conn, reader: reader}, nil
+}
+
+// Read 读取握手响应之后的原始数据 (含与响应一起到达的部分)
+func (c *httpUpgradeConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
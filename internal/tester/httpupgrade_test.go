@@ -0,0 +1,58 @@
+package tester
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"testing"
+)
+
+// startHTTPUpgradeServer 启动 HTTPUpgrade 测试服务器，承载 VLESS
+// 路径不符时返回 404，与 Xray 后接反向代理或回落站点时的表现一致
+func startHTTPUpgradeServer(t *testing.T, path string) string {
+	return startTCPServer(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		if req.URL.Path != path || req.Header.Get("Upgrade") != "websocket" {
+			io.WriteString(conn, "HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n")
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		serveVLESS(struct {
+			io.Reader
+			io.Writer
+		}{reader, conn}, testUUID)
+	})
+}
+
+func TestHTTPUpgradeTransport(t *testing.T) {
+	port := startHTTPUpgradeServer(t, "/upgrade")
+
+	tests := []struct {
+		name string
+		path string
+		want Failure
+	}{
+		{"ok", "/upgrade", FailureNone},
+		{"ok-early-data", "/upgrade?ed=2048", FailureNone},
+		{"wrong-path", "/other", FailureTransport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectFailure(t, &parser.Node{
+				Name:      tt.name,
+				Type:      parser.ProxyTypeVLESS,
+				Server:    "127.0.0.1",
+				Port:      port,
+				UUID:      testUUID,
+				Network:   "httpupgrade",
+				Transport: parser.Transport{Path: tt.path},
+			}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package tester
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMP 协议号，用于解析收到的报文
+const (
+	icmpProtocolIPv4 = 1
+	icmpProtocolIPv6 = 58
+)
+
+// ErrICMPFamilyUnavailable 只有一个地址族可以创建 ICMP 套接字
+// 此时 ICMP 测试仍然启用，另一地址族的节点跳过 ICMP 测试
+var ErrICMPFamilyUnavailable = errors.New("部分地址族无法进行ICMP测试")
+
+// errICMPSkipped 节点地址所属的地址族无法创建 ICMP 套接字
+var errICMPSkipped = errors.New("该地址族无法进行ICMP测试")
+
+// SetICMP 启用或关闭 ICMP Echo 测试
+// 启用时分别检查能否创建 IPv4 与 IPv6 的 ICMP 套接字：都无法创建时返回错误且不启用；
+// 只有一个地址族可用时仍然启用，并返回包装 ErrICMPFamilyUnavailable 的错误
+func (o *Options) SetICMP(enabled bool) error {
+	o.icmp = false
+	if !enabled {
+		return nil
+	}
+
+	for _, ipv6Target := range []bool{false, true} {
+		conn, _, err := listenICMP(ipv6Target)
+		if err == nil {
+			conn.Close()
+		}
+		o.icmpUnavailable[icmpFamily(ipv6Target)] = err
+	}
+	ipv4Err, ipv6Err := o.icmpUnavailable[icmpFamily(false)], o.icmpUnavailable[icmpFamily(true)]
+	if ipv4Err != nil && ipv6Err != nil {
+		return ipv4Err
+	}
+
+	o.icmp = true
+	switch {
+	case ipv4Err != nil:
+		return fmt.Errorf("%w: IPv4 %w", ErrICMPFamilyUnavailable, ipv4Err)
+	case ipv6Err != nil:
+		return fmt.Errorf("%w: IPv6 %w", ErrICMPFamilyUnavailable, ipv6Err)
+	}
+	return nil
+}
+
+// icmpFamily 返回地址族在 Options.icmpUnavailable 中的下标
+func icmpFamily(ipv6Target bool) int {
+	if ipv6Target {
+		return 1
+	}
+	return 0
+}
+
+// resolveIPAddr 解析 host 的地址，与 net.ResolveIPAddr 一样优先返回 IPv4 地址
+// 解析可被 ctx 取消，用户中断或到达时限时不必等待 DNS 超时
+func resolveIPAddr(ctx context.Context, host string) (*net.IPAddr, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	for i := range addrs {
+		if addrs[i].IP.To4() != nil {
+			return &addrs[i], nil
+		}
+	}
+	return &addrs[0], nil
+}
+
+// icmpPing 向节点服务器发送一次 ICMP Echo 请求，返回往返延迟
+// 可用于区分“主机在线但端口被过滤”与“主机不可达”；地址族在 SetICMP 时不可用则返回 errICMPSkipped
+func (o *Options) icmpPing(ctx context.Context, host string, timeout time.Duration) (int, error) {
+	addr, err := resolveIPAddr(ctx, host)
+	if err != nil {
+		return -1, fmt.Errorf("解析地址失败: %w", err)
+	}
+	ipv6Target := addr.IP.To4() == nil
+	if o.icmpUnavailable[icmpFamily(ipv6Target)] != nil {
+		return -1, errICMPSkipped
+	}
+
+	conn, raw, err := listenICMP(ipv6Target)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+	// 取消时关闭套接字，使等待中的读取立即返回
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var requestType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	protocol := icmpProtocolIPv4
+	if ipv6Target {
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		protocol = icmpProtocolIPv6
+	}
+
+	// 原始套接字会收到本机所有 ICMP 报文，使用随机标识与序号区分各次请求；
+	// 数据报套接字的标识由内核改写为本地端口，内核只投递与之匹配的回复
+	var token [4]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return -1, err
+	}
+	id := int(binary.BigEndian.Uint16(token[:2]))
+	seq := int(binary.BigEndian.Uint16(token[2:]))
+
+	request, err := (&icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("proxy-tester")},
+	}).Marshal(nil)
+	if err != nil {
+		return -1, err
+	}
+
+	var dst net.Addr = &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+	if raw {
+		dst = addr
+	}
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(timeout))
+
+	if _, err := conn.WriteTo(request, dst); err != nil {
+		return -1, fmt.Errorf("发送ICMP请求失败: %w", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return -1, fmt.Errorf("ICMP无响应: %w", err)
+		}
+		if !sameIP(from, addr.IP) {
+			continue
+		}
+
+		reply, err := icmp.ParseMessage(protocol, buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (raw && echo.ID != id) {
+			continue
+		}
+		return int(time.Since(start).Milliseconds()), nil
+	}
+}
+
+// listenICMP 创建 ICMP 套接字，raw 表示是否为原始套接字
+// 优先使用无需特权的数据报套接字 (Linux 需 net.ipv4.ping_group_range 包含当前用户组，macOS 默认可用)，
+// 失败时回退到需要 root 或 CAP_NET_RAW 的原始套接字
+func listenICMP(ipv6Target bool) (*icmp.PacketConn, bool, error) {
+	datagram, rawNetwork, address := "udp4", "ip4:icmp", "0.0.0.0"
+	if ipv6Target {
+		datagram, rawNetwork, address = "udp6", "ip6:ipv6-icmp", "::"
+	}
+
+	conn, err := icmp.ListenPacket(datagram, address)
+	if err == nil {
+		return conn, false, nil
+	}
+	conn, rawErr := icmp.ListenPacket(rawNetwork, address)
+	if rawErr == nil {
+		return conn, true, nil
+	}
+	return nil, false, fmt.Errorf("无法创建ICMP套接字(需要 ping_group_range 授权或 root 权限): 数据报套接字: %w; 原始套接字: %w", err, rawErr)
+}
+
+// sameIP 判断报文来源是否为目标地址
+func sameIP(from net.Addr, ip net.IP) bool {
+	switch addr := from.(type) {
+	case *net.UDPAddr:
+		return addr.IP.Equal(ip)
+	case *net.IPAddr:
+		return addr.IP.Equal(ip)
+	}
+	return false
+}
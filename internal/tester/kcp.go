@@ -0,0 +1,450 @@
+package tester
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"os"
+	"proxy-tester/internal/parser"
+	"time"
+)
+
+// mKCP 段命令
+const (
+	kcpCmdACK       = 0
+	kcpCmdData      = 1
+	kcpCmdTerminate = 2
+	kcpCmdPing      = 3
+)
+
+const (
+	kcpMTU             = 1350                   // 与 Xray 默认值一致
+	kcpDataOverhead    = 18                     // 数据段头长度
+	kcpWindow          = 128                    // 接收窗口 (段数)
+	kcpResendInterval  = 300 * time.Millisecond // 未确认数据段的重传间隔
+	kcpPollInterval    = 100 * time.Millisecond // 等待数据时检查重传的间隔
+	kcpMaxPacketLength = 2048
+)
+
+// kcpTransport 基于 UDP 的 mKCP 传输，可叠加 TLS
+type kcpTransport struct{}
+
+func (kcpTransport) dial(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	if node.Security.Type == "reality" {
+		return nil, unsupported(errors.New("mKCP 传输不支持 REALITY"))
+	}
+
+	header, err := newKCPHeader(node.Transport.HeaderType)
+	if err != nil {
+		return nil, err
+	}
+	security := newKCPSecurity(node.Transport.Seed)
+
+	// 使用直连 dialer 绕过系统代理
+	udp, err := getDirectDialer(timeout).DialContext(ctx, "udp", net.JoinHostPort(node.Server, node.Port))
+	if err != nil {
+		return nil, atStage(StageConnect, err)
+	}
+	// UDP 无需建立连接，DialContext 返回时只完成了域名解析
+	if net.ParseIP(node.Server) == nil {
+		timeline.mark(StageDNS)
+	}
+
+	var conv [2]byte
+	rand.Read(conv[:])
+	conn := closeOnCancel(ctx, &kcpConn{
+		Conn:     udp,
+		conv:     binary.BigEndian.Uint16(conv[:]),
+		header:   header,
+		security: security,
+		start:    time.Now(),
+		received: make(map[uint32][]byte),
+	})
+	if !node.TLS {
+		return conn, nil
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	tlsConn := tls.Client(conn, newTLSConfig(node))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, atStage(StageTLS, err)
+	}
+	timeline.mark(StageTLS)
+	return tlsConn, nil
+}
+
+// newKCPSecurity 设置 seed 时使用 AES-GCM 加密，否则使用简单混淆
+func newKCPSecurity(seed string) cipher.AEAD {
+	if seed == "" {
+		return kcpSimpleAuth{}
+	}
+	key := sha256.Sum256([]byte(seed))
+	return mustAESGCM(key[:16])
+}
+
+// kcpHeader 数据包伪装头，size 为长度，write 写入一个新的伪装头
+type kcpHeader struct {
+	size  int
+	write func(b []byte)
+}
+
+// newKCPHeader 按 headerType 构造与 Xray 一致的伪装头
+func newKCPHeader(headerType string) (kcpHeader, error) {
+	var random [4]byte
+	rand.Read(random[:])
+	counter := binary.BigEndian.Uint16(random[:])
+	epoch := binary.BigEndian.Uint16(random[2:])
+
+	switch headerType {
+	case "", "none":
+		return kcpHeader{}, nil
+	case "srtp":
+		return kcpHeader{size: 4, write: func(b []byte) {
+			counter++
+			binary.BigEndian.PutUint16(b, 0xB5E8)
+			binary.BigEndian.PutUint16(b[2:], counter)
+		}}, nil
+	case "utp":
+		return kcpHeader{size: 4, write: func(b []byte) {
+			binary.BigEndian.PutUint16(b, epoch)
+			b[2], b[3] = 1, 0
+		}}, nil
+	case "wechat-video":
+		sn := uint32(counter)
+		return kcpHeader{size: 13, write: func(b []byte) {
+			sn++
+			b[0], b[1] = 0xa1, 0x08
+			binary.BigEndian.PutUint32(b[2:], sn)
+			copy(b[6:], []byte{0x00, 0x10, 0x11, 0x18, 0x30, 0x22, 0x30})
+		}}, nil
+	case "dtls":
+		var sequence uint32
+		length := uint16(17)
+		return kcpHeader{size: 13, write: func(b []byte) {
+			b[0], b[1], b[2] = 23, 254, 253
+			binary.BigEndian.PutUint16(b[3:], epoch)
+			b[5], b[6] = 0, 0
+			binary.BigEndian.PutUint32(b[7:], sequence)
+			sequence++
+			binary.BigEndian.PutUint16(b[11:], length)
+			length += 17
+			if length > 100 {
+				length -= 50
+			}
+		}}, nil
+	case "wireguard":
+		return kcpHeader{size: 4, write: func(b []byte) {
+			copy(b, []byte{0x04, 0x00, 0x00, 0x00})
+		}}, nil
+	default:
+		return kcpHeader{}, unsupported(fmt.Errorf("不支持的mKCP伪装类型: %s", headerType))
+	}
+}
+
+// kcpSimpleAuth 未设置 seed 时使用的简单混淆
+// 格式: FNV-1a(4) + 长度(2) + 数据，整体按 4 字节向前异或
+type kcpSimpleAuth struct{}
+
+func (kcpSimpleAuth) NonceSize() int { return 0 }
+func (kcpSimpleAuth) Overhead() int  { return 6 }
+
+func (kcpSimpleAuth) Seal(dst, _, plain, _ []byte) []byte {
+	out := make([]byte, 6, 6+len(plain)+3)
+	binary.BigEndian.PutUint16(out[4:], uint16(len(plain)))
+	out = append(out, plain...)
+
+	hash := fnv.New32a()
+	hash.Write(out[4:])
+	hash.Sum(out[:0])
+
+	length := len(out)
+	out = append(out, make([]byte, (4-length%4)%4)...)
+	for i := 4; i < len(out); i++ {
+		out[i] ^= out[i-4]
+	}
+	return append(dst, out[:length]...)
+}
+
+func (kcpSimpleAuth) Open(dst, _, ciphertext, _ []byte) ([]byte, error) {
+	length := len(ciphertext)
+	if length < 6 {
+		return nil, errors.New("mKCP数据包校验失败")
+	}
+	out := make([]byte, length, length+3)
+	copy(out, ciphertext)
+	out = append(out, make([]byte, (4-length%4)%4)...)
+	for i := len(out) - 1; i >= 4; i-- {
+		out[i] ^= out[i-4]
+	}
+	out = out[:length]
+
+	hash := fnv.New32a()
+	hash.Write(out[4:])
+	if binary.BigEndian.Uint32(out) != hash.Sum32() || int(binary.BigEndian.Uint16(out[4:])) != length-6 {
+		return nil, errors.New("mKCP数据包校验失败")
+	}
+	return append(dst, out[6:]...), nil
+}
+
+// kcpConn mKCP 客户端连接
+// 测试只涉及少量数据，因此不做拥塞控制：写入时立即发送数据段，
+// 读取时处理确认、按序交付数据，并重传超时未确认的数据段
+type kcpConn struct {
+	net.Conn // 底层 UDP 连接
+
+	conv     uint16
+	header   kcpHeader
+	security cipher.AEAD
+	start    time.Time
+	deadline time.Time
+
+	// 发送状态
+	sendNext uint32
+	unacked  []*kcpSegment
+
+	// 接收状态
+	recvNext   uint32
+	received   map[uint32][]byte
+	pending    []byte
+	terminated bool
+}
+
+// kcpSegment 已发送但尚未确认的数据段
+type kcpSegment struct {
+	number uint32
+	packet []byte
+	sentAt time.Time
+}
+
+// elapsed 返回连接建立以来的毫秒数，用作段时间戳
+func (c *kcpConn) elapsed() uint32 {
+	return uint32(time.Since(c.start).Milliseconds())
+}
+
+// sendSegments 将若干段封装为一个数据包发送: 伪装头 + (nonce) + 加密后的段
+func (c *kcpConn) sendSegments(segments []byte) ([]byte, error) {
+	nonceSize := c.security.NonceSize()
+	packet := make([]byte, c.header.size+nonceSize, c.header.size+nonceSize+c.security.Overhead()+len(segments))
+	if c.header.write != nil {
+		c.header.write(packet)
+	}
+	nonce := packet[c.header.size:]
+	rand.Read(nonce)
+	packet = c.security.Seal(packet, nonce, segments, nil)
+
+	_, err := c.Conn.Write(packet)
+	return packet, err
+}
+
+// firstUnacked 返回最早未确认的序号
+func (c *kcpConn) firstUnacked() uint32 {
+	if len(c.unacked) > 0 {
+		return c.unacked[0].number
+	}
+	return c.sendNext
+}
+
+// Write 将数据拆分为数据段发送，未确认的段在读取时重传
+func (c *kcpConn) Write(p []byte) (int, error) {
+	maxPayload := kcpMTU - c.header.size - c.security.NonceSize() - c.security.Overhead() - kcpDataOverhead
+	for rest := p; len(rest) > 0; {
+		n := min(len(rest), maxPayload)
+
+		segment := make([]byte, kcpDataOverhead, kcpDataOverhead+n)
+		binary.BigEndian.PutUint16(segment, c.conv)
+		segment[2] = kcpCmdData
+		binary.BigEndian.PutUint32(segment[4:], c.elapsed())
+		binary.BigEndian.PutUint32(segment[8:], c.sendNext)
+		binary.BigEndian.PutUint32(segment[12:], c.firstUnacked())
+		binary.BigEndian.PutUint16(segment[16:], uint16(n))
+		segment = append(segment, rest[:n]...)
+
+		packet, err := c.sendSegments(segment)
+		if err != nil {
+			return len(p) - len(rest), err
+		}
+		c.unacked = append(c.unacked, &kcpSegment{number: c.sendNext, packet: packet, sentAt: time.Now()})
+		c.sendNext++
+		rest = rest[n:]
+	}
+	return len(p), nil
+}
+
+// Read 按序读取服务器发送的数据
+func (c *kcpConn) Read(p []byte) (int, error) {
+	buf := make([]byte, kcpMaxPacketLength)
+	for len(c.pending) == 0 {
+		if c.terminated {
+			return 0, io.EOF
+		}
+		c.retransmit()
+
+		// 以较短的间隔等待数据包，以便及时重传
+		wait := time.Now().Add(kcpPollInterval)
+		if !c.deadline.IsZero() && c.deadline.Before(wait) {
+			wait = c.deadline
+		}
+		c.Conn.SetReadDeadline(wait)
+
+		n, err := c.Conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) && (c.deadline.IsZero() || time.Now().Before(c.deadline)) {
+				continue
+			}
+			return 0, err
+		}
+		if err := c.handlePacket(buf[:n]); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// retransmit 重传超时未确认的数据段
+func (c *kcpConn) retransmit() {
+	for _, seg := range c.unacked {
+		if time.Since(seg.sentAt) >= kcpResendInterval {
+			c.Conn.Write(seg.packet)
+			seg.sentAt = time.Now()
+		}
+	}
+}
+
+// handlePacket 解密并处理一个数据包中的所有段，对收到的数据段回复确认
+// 无法解密的数据包直接丢弃，与服务器的处理方式一致
+func (c *kcpConn) handlePacket(packet []byte) error {
+	nonceSize := c.security.NonceSize()
+	if len(packet) <= c.header.size+nonceSize+c.security.Overhead() {
+		return nil
+	}
+	packet = packet[c.header.size:]
+	segments, err := c.security.Open(nil, packet[:nonceSize], packet[nonceSize:], nil)
+	if err != nil {
+		return nil
+	}
+
+	var acks []uint32
+	var ackTimestamp uint32
+	for len(segments) >= 4 {
+		conv := binary.BigEndian.Uint16(segments)
+		cmd := segments[2]
+		body := segments[4:]
+
+		switch cmd {
+		case kcpCmdData:
+			if len(body) < 14 || len(body)-14 < int(binary.BigEndian.Uint16(body[12:])) {
+				return nil
+			}
+			length := int(binary.BigEndian.Uint16(body[12:]))
+			number := binary.BigEndian.Uint32(body[4:])
+			if conv == c.conv {
+				ackTimestamp = binary.BigEndian.Uint32(body)
+				acks = append(acks, number)
+				if number-c.recvNext < kcpWindow {
+					if _, ok := c.received[number]; !ok {
+						c.received[number] = append([]byte(nil), body[14:14+length]...)
+					}
+				}
+			}
+			segments = body[14+length:]
+		case kcpCmdACK:
+			if len(body) < 13 || len(body)-13 < int(body[12])*4 {
+				return nil
+			}
+			count := int(body[12])
+			if conv == c.conv {
+				c.acknowledge(binary.BigEndian.Uint32(body[4:]), body[13:13+count*4])
+			}
+			segments = body[13+count*4:]
+		default:
+			if len(body) < 12 {
+				return nil
+			}
+			if conv == c.conv {
+				c.acknowledge(binary.BigEndian.Uint32(body[4:]), nil)
+				if cmd == kcpCmdTerminate {
+					c.terminated = true
+				}
+			}
+			segments = body[12:]
+		}
+	}
+
+	// 按序交付已收到的数据
+	for {
+		data, ok := c.received[c.recvNext]
+		if !ok {
+			break
+		}
+		delete(c.received, c.recvNext)
+		c.pending = append(c.pending, data...)
+		c.recvNext++
+	}
+
+	if len(acks) == 0 {
+		return nil
+	}
+	ack := make([]byte, 17, 17+len(acks)*4)
+	binary.BigEndian.PutUint16(ack, c.conv)
+	ack[2] = kcpCmdACK
+	binary.BigEndian.PutUint32(ack[4:], c.recvNext+kcpWindow)
+	binary.BigEndian.PutUint32(ack[8:], c.recvNext)
+	binary.BigEndian.PutUint32(ack[12:], ackTimestamp)
+	ack[16] = byte(len(acks))
+	for _, number := range acks {
+		ack = binary.BigEndian.AppendUint32(ack, number)
+	}
+	_, err = c.sendSegments(ack)
+	return err
+}
+
+// acknowledge 移除对方已确认的数据段: 小于 receivingNext 的序号及 numbers 中列出的序号
+func (c *kcpConn) acknowledge(receivingNext uint32, numbers []byte) {
+	acked := make(map[uint32]bool, len(numbers)/4)
+	for i := 0; i+4 <= len(numbers); i += 4 {
+		acked[binary.BigEndian.Uint32(numbers[i:])] = true
+	}
+
+	remaining := c.unacked[:0]
+	for _, seg := range c.unacked {
+		if int32(seg.number-receivingNext) >= 0 && !acked[seg.number] {
+			remaining = append(remaining, seg)
+		}
+	}
+	c.unacked = remaining
+}
+
+// SetDeadline 记录读取截止时间，读取时据此安排重传
+func (c *kcpConn) SetDeadline(t time.Time) error {
+	c.deadline = t
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline 记录读取截止时间
+func (c *kcpConn) SetReadDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+// Close 通知服务器结束会话并关闭 UDP 连接
+func (c *kcpConn) Close() error {
+	segment := make([]byte, 16)
+	binary.BigEndian.PutUint16(segment, c.conv)
+	segment[2] = kcpCmdTerminate
+	binary.BigEndian.PutUint32(segment[4:], c.sendNext)
+	binary.BigEndian.PutUint32(segment[8:], c.recvNext)
+	c.sendSegments(segment)
+	return c.Conn.Close()
+}
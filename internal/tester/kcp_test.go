@@ -0,0 +1,120 @@
+package tester
+
+import (
+	"encoding/binary"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+// udpPeerConn 将监听中的 UDP 套接字绑定到单个对端：首次读取返回已收到的首个数据包，之后只接收该对端的数据包
+type udpPeerConn struct {
+	*net.UDPConn
+	peer  *net.UDPAddr
+	first []byte
+}
+
+func (c *udpPeerConn) Read(p []byte) (int, error) {
+	if c.first != nil {
+		n := copy(p, c.first)
+		c.first = nil
+		return n, nil
+	}
+	for {
+		n, addr, err := c.UDPConn.ReadFromUDP(p)
+		if err != nil || addr.String() == c.peer.String() {
+			return n, err
+		}
+	}
+}
+
+func (c *udpPeerConn) Write(p []byte) (int, error) {
+	return c.UDPConn.WriteToUDP(p, c.peer)
+}
+
+// startKCPServer 启动使用指定伪装头与 seed 的 mKCP 测试服务器，承载 VLESS，只服务首个客户端
+// 与 Xray 一致，无法解密的数据包直接丢弃而不作任何回应
+func startKCPServer(t *testing.T, headerType, seed string) string {
+	t.Helper()
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { udp.Close() })
+
+	header, err := newKCPHeader(headerType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	security := newKCPSecurity(seed)
+
+	go func() {
+		buf := make([]byte, kcpMaxPacketLength)
+		var peer *net.UDPAddr
+		var first, segments []byte
+		for {
+			n, addr, err := udp.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			nonceSize := security.NonceSize()
+			if n <= header.size+nonceSize+security.Overhead() {
+				continue
+			}
+			packet := buf[header.size:n]
+			if segments, err = security.Open(nil, packet[:nonceSize], packet[nonceSize:], nil); err == nil && len(segments) >= 2 {
+				peer, first = addr, append([]byte(nil), buf[:n]...)
+				break
+			}
+		}
+
+		conn := &kcpConn{
+			Conn:     &udpPeerConn{UDPConn: udp, peer: peer, first: first},
+			conv:     binary.BigEndian.Uint16(segments),
+			header:   header,
+			security: security,
+			start:    time.Now(),
+			received: make(map[uint32][]byte),
+		}
+		defer conn.Close()
+		serveVLESS(conn, testUUID)
+	}()
+
+	_, port, _ := net.SplitHostPort(udp.LocalAddr().String())
+	return port
+}
+
+func TestKCPTransport(t *testing.T) {
+	tests := []struct {
+		name             string
+		serverHeaderType string
+		serverSeed       string
+		headerType       string
+		seed             string
+		want             Failure
+	}{
+		{"ok", "", "", "", "", FailureNone},
+		{"ok-seed-srtp", "srtp", "seed", "srtp", "seed", FailureNone},
+		{"ok-wechat-video", "wechat-video", "", "wechat-video", "", FailureNone},
+		// 数据包被服务器丢弃，表现为等待响应超时
+		{"wrong-seed", "", "seed", "", "other", FailureNoResponse},
+		{"wrong-header-type", "dtls", "", "", "", FailureNoResponse},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			port := startKCPServer(t, tt.serverHeaderType, tt.serverSeed)
+			expectFailure(t, &parser.Node{
+				Name:      tt.name,
+				Type:      parser.ProxyTypeVLESS,
+				Server:    "127.0.0.1",
+				Port:      port,
+				UUID:      testUUID,
+				Network:   "kcp",
+				Transport: parser.Transport{HeaderType: tt.headerType, Seed: tt.seed},
+			}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package tester
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// Mux.Cool 会话状态
+const (
+	muxStatusNew       = 0x01
+	muxStatusKeep      = 0x02
+	muxStatusEnd       = 0x03
+	muxStatusKeepAlive = 0x04
+)
+
+// muxOptionData 帧中携带数据
+const muxOptionData = 0x01
+
+// muxNetworkTCP 新建会话的网络类型
+const muxNetworkTCP = 0x01
+
+// muxConn V2Ray Mux.Cool 多路复用协议上的单个 TCP 会话
+// v2ray-plugin 默认启用多路复用，服务器端期望收到 Mux.Cool 帧
+type muxConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	sessionID uint16
+	target    []byte // Mux.Cool 格式的目标: 端口 + 地址
+	started   bool
+	pending   []byte
+}
+
+// newMuxConn 在已建立的连接上创建一个 Mux.Cool 会话
+func newMuxConn(conn net.Conn, host string, port int) *muxConn {
+	return &muxConn{
+		Conn:      conn,
+		reader:    bufio.NewReader(conn),
+		sessionID: 1,
+		target:    encodeMuxAddr(host, port),
+	}
+}
+
+// encodeMuxAddr 按 Mux.Cool 格式编码目标地址 (PORT + ATYP + ADDR)
+// 与 SOCKS5 不同，Mux.Cool 的地址类型为 1:IPv4 2:域名 3:IPv6，且端口在前
+func encodeMuxAddr(host string, port int) []byte {
+	buf := binary.BigEndian.AppendUint16(nil, uint16(port))
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, 0x01)
+			return append(buf, ip4...)
+		}
+		buf = append(buf, 0x03)
+		return append(buf, ip.To16()...)
+	}
+	buf = append(buf, 0x02, byte(len(host)))
+	return append(buf, host...)
+}
+
+func (c *muxConn) Write(p []byte) (int, error) {
+	metadata := binary.BigEndian.AppendUint16(nil, c.sessionID)
+	if !c.started {
+		metadata = append(metadata, muxStatusNew, muxOptionData, muxNetworkTCP)
+		metadata = append(metadata, c.target...)
+		c.started = true
+	} else {
+		metadata = append(metadata, muxStatusKeep, muxOptionData)
+	}
+
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(metadata)))
+	frame = append(frame, metadata...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(p)))
+	frame = append(frame, p...)
+
+	if _, err := c.Conn.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *muxConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if err := c.readFrame(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readFrame 读取一个 Mux.Cool 帧，仅保留属于当前会话的数据
+func (c *muxConn) readFrame() error {
+	var lengthBuf [2]byte
+	if _, err := io.ReadFull(c.reader, lengthBuf[:]); err != nil {
+		return err
+	}
+	metadata := make([]byte, binary.BigEndian.Uint16(lengthBuf[:]))
+	if _, err := io.ReadFull(c.reader, metadata); err != nil {
+		return err
+	}
+	if len(metadata) < 4 {
+		return fmt.Errorf("无效的 Mux.Cool 帧")
+	}
+
+	sessionID := binary.BigEndian.Uint16(metadata)
+	status := metadata[2]
+	option := metadata[3]
+
+	var data []byte
+	if option&muxOptionData != 0 {
+		if _, err := io.ReadFull(c.reader, lengthBuf[:]); err != nil {
+			return err
+		}
+		data = make([]byte, binary.BigEndian.Uint16(lengthBuf[:]))
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return err
+		}
+	}
+
+	if sessionID != c.sessionID || status == muxStatusKeepAlive {
+		return nil
+	}
+	if status == muxStatusEnd && len(data) == 0 {
+		return io.EOF
+	}
+	c.pending = data
+	return nil
+}
@@ -0,0 +1,318 @@
+package tester
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsePluginOpts 解析 SIP003 插件参数，如 obfs=http;obfs-host=example.com;tls
+// 不带值的参数 (如 tls) 的值为空字符串，反斜杠用于转义分号与等号
+func parsePluginOpts(opts string) map[string]string {
+	result := make(map[string]string)
+
+	var key, value strings.Builder
+	inValue := false
+	flush := func() {
+		if key.Len() > 0 {
+			result[key.String()] = value.String()
+		}
+		key.Reset()
+		value.Reset()
+		inValue = false
+	}
+
+	for i := 0; i < len(opts); i++ {
+		c := opts[i]
+		switch {
+		case c == '\\' && i+1 < len(opts):
+			i++
+			c = opts[i]
+		case c == ';':
+			flush()
+			continue
+		case c == '=' && !inValue:
+			inValue = true
+			continue
+		}
+		if inValue {
+			value.WriteByte(c)
+		} else {
+			key.WriteByte(c)
+		}
+	}
+	flush()
+
+	return result
+}
+
+// wrapPlugin 在到服务器的 TCP 连接上套用 SIP003 插件的客户端行为
+// v2ray-plugin 会立即完成 (TLS +) WebSocket 握手；simple-obfs 的伪装头随第一个数据包发送
+func wrapPlugin(conn net.Conn, node *parser.Node, timeout time.Duration) (net.Conn, error) {
+	opts := parsePluginOpts(node.PluginOpts)
+
+	switch node.Plugin {
+	case "obfs-local", "simple-obfs":
+		host := opts["obfs-host"]
+		if host == "" {
+			host = node.Server
+		}
+		switch opts["obfs"] {
+		case "http":
+			uri := opts["obfs-uri"]
+			if uri == "" {
+				uri = "/"
+			}
+			return &obfsHTTPConn{Conn: conn, host: host, uri: uri}, nil
+		case "tls":
+			return &obfsTLSConn{Conn: conn, host: host}, nil
+		default:
+			return nil, unsupported(fmt.Errorf("不支持的 simple-obfs 模式: %s", opts["obfs"]))
+		}
+
+	case "v2ray-plugin":
+		if mode, ok := opts["mode"]; ok && mode != "websocket" {
+			return nil, unsupported(fmt.Errorf("不支持的 v2ray-plugin 模式: %s", mode))
+		}
+		host := opts["host"]
+		if host == "" {
+			host = "cloudfront.com"
+		}
+
+		if _, ok := opts["tls"]; ok {
+			tlsConn := tls.Client(conn, &tls.Config{
+				ServerName:         host,
+				InsecureSkipVerify: node.Security.AllowInsecure,
+			})
+			tlsConn.SetDeadline(time.Now().Add(timeout))
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, fmt.Errorf("v2ray-plugin TLS握手失败: %w", err)
+			}
+			conn = tlsConn
+		}
+
+		ws, err := dialWebSocket(conn, host, opts["path"])
+		if err != nil {
+			return nil, fmt.Errorf("v2ray-plugin %w", err)
+		}
+		// 除非显式设置 mux=0，v2ray-plugin 的数据都承载在 Mux.Cool 会话中
+		if opts["mux"] == "0" {
+			return ws, nil
+		}
+		// 服务器端会将会话重定向到本地的 Shadowsocks 服务，目标地址仅作占位
+		port, _ := strconv.Atoi(node.Port)
+		return newMuxConn(ws, "127.0.0.1", port), nil
+
+	default:
+		return nil, unsupported(fmt.Errorf("不支持的插件: %s", node.Plugin))
+	}
+}
+
+// obfsHTTPConn simple-obfs 的 HTTP 伪装
+// 首个数据包伪装为 WebSocket 升级请求的请求体，服务器以 101 响应头开始回复
+type obfsHTTPConn struct {
+	net.Conn
+	host string
+	uri  string
+
+	requestSent  bool
+	responseRead bool
+	reader       *bufio.Reader
+}
+
+func (c *obfsHTTPConn) Write(p []byte) (int, error) {
+	if c.requestSent {
+		return c.Conn.Write(p)
+	}
+	c.requestSent = true
+
+	keyBytes := make([]byte, 16)
+	rand.Read(keyBytes)
+
+	request := fmt.Sprintf("GET %s HTTP/1.1\r\n"+
+		"Host: %s\r\n"+
+		"User-Agent: curl/7.88.1\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\n"+
+		"Content-Length: %d\r\n\r\n",
+		c.uri, c.host, base64.StdEncoding.EncodeToString(keyBytes), len(p))
+
+	if _, err := c.Conn.Write(append([]byte(request), p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *obfsHTTPConn) Read(p []byte) (int, error) {
+	if !c.responseRead {
+		c.reader = bufio.NewReader(c.Conn)
+		resp, err := http.ReadResponse(c.reader, nil)
+		if err != nil {
+			return 0, fmt.Errorf("simple-obfs 响应无效: %w", err)
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			return 0, fmt.Errorf("simple-obfs 响应状态码错误: %d", resp.StatusCode)
+		}
+		c.responseRead = true
+	}
+	return c.reader.Read(p)
+}
+
+// TLS 记录类型
+const (
+	tlsRecordChangeCipherSpec = 0x14
+	tlsRecordHandshake        = 0x16
+	tlsRecordApplicationData  = 0x17
+)
+
+// obfsTLSCipherSuites simple-obfs 伪装 ClientHello 中使用的加密套件列表
+var obfsTLSCipherSuites = []byte{
+	0xc0, 0x2c, 0xc0, 0x30, 0x00, 0x9f, 0xcc, 0xa9, 0xcc, 0xa8, 0xcc, 0xaa, 0xc0, 0x2b, 0xc0, 0x2f,
+	0x00, 0x9e, 0xc0, 0x24, 0xc0, 0x28, 0x00, 0x6b, 0xc0, 0x23, 0xc0, 0x27, 0x00, 0x67, 0xc0, 0x0a,
+	0xc0, 0x14, 0x00, 0x39, 0xc0, 0x09, 0xc0, 0x13, 0x00, 0x33, 0x00, 0x9d, 0x00, 0x9c, 0x00, 0x3d,
+	0x00, 0x3c, 0x00, 0x35, 0x00, 0x2f, 0x00, 0xff,
+}
+
+// obfsTLSOtherExtensions ClientHello 中固定的其他扩展
+// ec_point_formats, supported_groups, signature_algorithms, encrypt_then_mac, extended_master_secret
+var obfsTLSOtherExtensions = []byte{
+	0x00, 0x0b, 0x00, 0x04, 0x03, 0x00, 0x01, 0x02,
+	0x00, 0x0a, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x1d, 0x00, 0x17, 0x00, 0x19, 0x00, 0x18,
+	0x00, 0x0d, 0x00, 0x20, 0x00, 0x1e,
+	0x06, 0x01, 0x06, 0x02, 0x06, 0x03, 0x05, 0x01, 0x05, 0x02, 0x05, 0x03, 0x04, 0x01, 0x04, 0x02,
+	0x04, 0x03, 0x03, 0x01, 0x03, 0x02, 0x03, 0x03, 0x02, 0x01, 0x02, 0x02, 0x02, 0x03,
+	0x00, 0x16, 0x00, 0x00,
+	0x00, 0x17, 0x00, 0x00,
+}
+
+// obfsTLSMaxRecord 单个 TLS 记录的最大负载
+const obfsTLSMaxRecord = 16384
+
+// obfsTLSConn simple-obfs 的 TLS 伪装
+// 首个数据包放入伪造 ClientHello 的 SessionTicket 扩展，之后的数据封装为 ApplicationData 记录
+type obfsTLSConn struct {
+	net.Conn
+	host string
+
+	helloSent bool
+	remaining int // 当前 ApplicationData 记录剩余的负载长度
+	firstRead bool
+}
+
+func (c *obfsTLSConn) Write(p []byte) (int, error) {
+	if !c.helloSent {
+		c.helloSent = true
+		if _, err := c.Conn.Write(c.clientHello(p)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > obfsTLSMaxRecord {
+			chunk = chunk[:obfsTLSMaxRecord]
+		}
+		record := make([]byte, 0, 5+len(chunk))
+		record = append(record, tlsRecordApplicationData, 0x03, 0x03)
+		record = binary.BigEndian.AppendUint16(record, uint16(len(chunk)))
+		record = append(record, chunk...)
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// clientHello 构造携带首个数据包的伪造 ClientHello
+func (c *obfsTLSConn) clientHello(payload []byte) []byte {
+	// 扩展: session_ticket(携带数据) + server_name + 其他固定扩展
+	var extensions []byte
+	extensions = append(extensions, 0x00, 0x23)
+	extensions = binary.BigEndian.AppendUint16(extensions, uint16(len(payload)))
+	extensions = append(extensions, payload...)
+
+	extensions = append(extensions, 0x00, 0x00)
+	extensions = binary.BigEndian.AppendUint16(extensions, uint16(len(c.host)+5))
+	extensions = binary.BigEndian.AppendUint16(extensions, uint16(len(c.host)+3))
+	extensions = append(extensions, 0x00)
+	extensions = binary.BigEndian.AppendUint16(extensions, uint16(len(c.host)))
+	extensions = append(extensions, c.host...)
+
+	extensions = append(extensions, obfsTLSOtherExtensions...)
+
+	// ClientHello 正文
+	body := []byte{0x03, 0x03}
+	random := make([]byte, 32)
+	rand.Read(random)
+	binary.BigEndian.PutUint32(random, uint32(time.Now().Unix()))
+	body = append(body, random...)
+
+	sessionID := make([]byte, 32)
+	rand.Read(sessionID)
+	body = append(body, byte(len(sessionID)))
+	body = append(body, sessionID...)
+
+	body = binary.BigEndian.AppendUint16(body, uint16(len(obfsTLSCipherSuites)))
+	body = append(body, obfsTLSCipherSuites...)
+	body = append(body, 0x01, 0x00) // 压缩方法: null
+
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+	body = append(body, extensions...)
+
+	// 握手消息头 + 记录头
+	handshake := []byte{0x01, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+
+	record := []byte{tlsRecordHandshake, 0x03, 0x01}
+	record = binary.BigEndian.AppendUint16(record, uint16(len(handshake)))
+	return append(record, handshake...)
+}
+
+func (c *obfsTLSConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		var header [5]byte
+		if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:]))
+
+		// 服务器首先返回伪造的 ServerHello
+		if !c.firstRead && header[0] != tlsRecordHandshake {
+			return 0, fmt.Errorf("simple-obfs 响应无效: 期望 ServerHello，收到记录类型 0x%02x", header[0])
+		}
+		c.firstRead = true
+
+		switch header[0] {
+		case tlsRecordApplicationData:
+			c.remaining = length
+		case tlsRecordHandshake, tlsRecordChangeCipherSpec:
+			if _, err := io.CopyN(io.Discard, c.Conn, int64(length)); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("simple-obfs 响应无效: 未知记录类型 0x%02x", header[0])
+		}
+	}
+
+	if len(p) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.Conn.Read(p)
+	c.remaining -= n
+	return n, err
+}
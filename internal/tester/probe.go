@@ -0,0 +1,245 @@
+package tester
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProbeURL 默认探测地址，与 Clash url-test 常用地址一致
+const DefaultProbeURL = "http://www.gstatic.com/generate_204"
+
+// probeTarget 握手完成后通过代理隧道请求的探测目标，验证端到端连通性
+type probeTarget struct {
+	tls     bool   // https 探测需在隧道内完成 TLS 握手
+	host    string // 目标主机，同时作为 Host 头与 SNI
+	port    int
+	uri     string // 请求路径与查询参数
+	method  string // 请求方法
+	status  int    // 期望的状态码，0 表示接受任意状态码
+	request []byte // 预先构造的请求报文
+}
+
+// defaultProbe 默认的探测目标，NewOptions 与直接调用 Protocol.Probe 时使用
+var defaultProbe = mustProbeTarget(DefaultProbeURL, http.MethodGet, http.StatusNoContent)
+
+// SetProbe 设置探测目标：method 为 GET 或 HEAD，expectStatus 为 0 时接受任意状态码
+func (o *Options) SetProbe(rawURL string, method string, expectStatus int) error {
+	if method = strings.ToUpper(method); method != http.MethodGet && method != http.MethodHead {
+		return fmt.Errorf("探测请求方法仅支持 GET/HEAD: %s", method)
+	}
+	target, err := newProbeTarget(rawURL, method, expectStatus, 0)
+	if err != nil {
+		return err
+	}
+	o.probe = target
+	return nil
+}
+
+// newProbeTarget 解析探测地址并预先构造请求头
+// contentLength 大于 0 时请求携带该长度的请求体，由调用方在请求头之后写入
+func newProbeTarget(rawURL string, method string, expectStatus int, contentLength int64) (*probeTarget, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("无效的探测地址: %w", err)
+	}
+
+	target := &probeTarget{host: u.Hostname(), uri: u.RequestURI(), method: strings.ToUpper(method), status: expectStatus}
+	switch u.Scheme {
+	case "http":
+		target.port = 80
+	case "https":
+		target.tls = true
+		target.port = 443
+	default:
+		return nil, fmt.Errorf("探测地址仅支持 http/https: %s", rawURL)
+	}
+	if target.host == "" {
+		return nil, fmt.Errorf("探测地址缺少主机名: %s", rawURL)
+	}
+	if port := u.Port(); port != "" {
+		target.port, err = strconv.Atoi(port)
+		if err != nil || target.port <= 0 || target.port > 65535 {
+			return nil, fmt.Errorf("无效的探测端口: %s", port)
+		}
+	}
+	if expectStatus < 0 || expectStatus > 999 {
+		return nil, fmt.Errorf("无效的期望状态码: %d", expectStatus)
+	}
+
+	request := target.method + " " + target.uri + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"User-Agent: proxy-tester\r\n"
+	if contentLength > 0 {
+		request += "Content-Type: application/octet-stream\r\n" +
+			"Content-Length: " + strconv.FormatInt(contentLength, 10) + "\r\n"
+	}
+	target.request = []byte(request + "Connection: close\r\n\r\n")
+	return target, nil
+}
+
+// mustProbeTarget 解析内置的探测目标
+func mustProbeTarget(rawURL string, method string, expectStatus int) *probeTarget {
+	target, err := newProbeTarget(rawURL, method, expectStatus, 0)
+	if err != nil {
+		panic(err)
+	}
+	return target
+}
+
+// probeTunnel 经已建立的代理隧道请求探测目标 target，返回收到响应首字节的时刻
+// 协议请求头由 conn 在首次写入时附加，http 探测的请求报文因此与请求头一起发送
+// 请求头发出时记录协议握手阶段结束，收到响应首字节时记录探测阶段结束
+func probeTunnel(conn net.Conn, target *probeTarget, timeline *Timeline) (time.Time, error) {
+	stage := StageProbe
+	if _, ok := conn.(unverifiedTunnel); ok {
+		stage = StageHandshake
+	}
+	resp, firstByte, err := target.send(&headerSentConn{Conn: conn, timeline: timeline}, stage)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp.Body.Close()
+	timeline.markAt(StageProbe, firstByte)
+	return firstByte, nil
+}
+
+// unverifiedTunnel 由不校验服务器应答的隧道实现，如 Trojan
+// 认证失败时服务器把请求转交给回落站点，回落站点的应答在协议层面与探测目标的应答无法区分；
+// 经此类隧道收到的非预期应答 (状态码不符、TLS 握手失败等) 归为协议握手阶段
+type unverifiedTunnel interface {
+	unverifiedResponse()
+}
+
+// send 经隧道发送预先构造的请求并读取响应头，返回响应与收到首字节的时刻
+// 状态码校验通过时响应体由调用方读取并关闭；stage 为非预期应答所归属的阶段，见 unverifiedTunnel
+func (t *probeTarget) send(conn net.Conn, stage Stage) (*http.Response, time.Time, error) {
+	conn, err := t.open(conn, stage)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if _, err := conn.Write(t.request); err != nil {
+		return nil, time.Time{}, fmt.Errorf("发送探测请求失败: %w", err)
+	}
+	return t.readResponse(conn, stage)
+}
+
+// open 返回与目标通信的连接，https 目标先在隧道内完成 TLS 握手
+func (t *probeTarget) open(conn net.Conn, stage Stage) (net.Conn, error) {
+	if !t.tls {
+		return conn, nil
+	}
+	// 探测目标是公开站点，始终校验证书，与节点的 AllowInsecure 无关
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName: t.host,
+		NextProtos: []string{"http/1.1"},
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, probeError(stage, fmt.Errorf("探测目标TLS握手失败: %w", err))
+	}
+	return tlsConn, nil
+}
+
+// readResponse 读取响应头并校验状态码，返回响应与收到首字节的时刻
+func (t *probeTarget) readResponse(conn net.Conn, stage Stage) (*http.Response, time.Time, error) {
+	reader := &firstByteReader{Reader: conn}
+	resp, err := http.ReadResponse(bufio.NewReader(reader), &http.Request{Method: t.method})
+	if err != nil {
+		return nil, time.Time{}, probeError(stage, fmt.Errorf("读取探测响应失败: %w", err))
+	}
+
+	if t.status != 0 && resp.StatusCode != t.status {
+		resp.Body.Close()
+		if stage != StageProbe {
+			return nil, time.Time{}, atStage(stage, fmt.Errorf("收到非探测目标的响应(疑为回落站点): HTTP %d (期望 %d)", resp.StatusCode, t.status))
+		}
+		return nil, time.Time{}, atStage(stage, fmt.Errorf("探测响应状态码错误: %d (期望 %d)", resp.StatusCode, t.status))
+	}
+	return resp, reader.first, nil
+}
+
+// probeError 将探测目标返回的错误标记为 stage 阶段
+// 隧道连接本身的关闭或超时不归咎于探测目标，由上层按协议握手阶段分类
+func probeError(stage Stage, err error) error {
+	if isConnectionError(err) {
+		return err
+	}
+	return atStage(stage, err)
+}
+
+// headerSentConn 在首次写入完成时记录协议握手阶段结束
+// 内置协议的请求头都随首个数据包发送 (0-RTT)，首次写入完成即请求头已发出
+type headerSentConn struct {
+	net.Conn
+	timeline *Timeline
+	sent     bool
+}
+
+func (c *headerSentConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if err == nil && !c.sent {
+		c.sent = true
+		c.timeline.mark(StageHandshake)
+	}
+	return n, err
+}
+
+// firstByteReader 记录首次读到数据的时刻
+type firstByteReader struct {
+	io.Reader
+	first time.Time
+}
+
+func (r *firstByteReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 && r.first.IsZero() {
+		r.first = time.Now()
+	}
+	return n, err
+}
+
+// prefixConn 首次写入时在数据前附加 prefix，用于将协议请求头与首个数据包一起发送
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Write(p []byte) (int, error) {
+	if c.prefix == nil {
+		return c.Conn.Write(p)
+	}
+	buf := append(c.prefix, p...)
+	c.prefix = nil
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// encodeSocksAddr 按 SOCKS5 地址格式编码目标地址 (ATYP + ADDR + PORT)
+// Trojan 与 Shadowsocks 均使用该格式描述目标地址
+func encodeSocksAddr(host string, port int) []byte {
+	var buf []byte
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, 0x01)
+			buf = append(buf, ip4...)
+		} else {
+			buf = append(buf, 0x04)
+			buf = append(buf, ip.To16()...)
+		}
+	} else {
+		buf = append(buf, 0x03, byte(len(host)))
+		buf = append(buf, host...)
+	}
+	return binary.BigEndian.AppendUint16(buf, uint16(port))
+}
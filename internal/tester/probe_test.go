@@ -0,0 +1,31 @@
+package tester
+
+import (
+	"context"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+)
+
+// 校验服务器应答的隧道中，状态码不符归咎于探测目标
+func TestProbeStatusMismatch(t *testing.T) {
+	t.Parallel()
+	opts := NewOptions()
+	if err := opts.SetProbe("http://www.gstatic.com/generate_204", "GET", 200); err != nil {
+		t.Fatal(err)
+	}
+	port := startTCPServer(t, func(conn net.Conn) {
+		serveVLESS(conn, testUUID)
+	})
+
+	_, err := testProxyConnection(context.Background(), &parser.Node{
+		Name:   "status-mismatch",
+		Type:   parser.ProxyTypeVLESS,
+		Server: "127.0.0.1",
+		Port:   port,
+		UUID:   testUUID,
+	}, testTimeout, opts.probe, nil)
+	if got := classifyError(err); got != FailureProbeHTTP {
+		t.Errorf("失败原因为 %s (%v)，期望 %s", got, err, FailureProbeHTTP)
+	}
+}
@@ -1,6 +1,7 @@
 package tester
 
 import (
+    "context"
     "crypto/tls"
     "fmt"
     "net"
@@ -41,140 +42,227 @@ func getDirectDialer(timeout time.Duration) *net.Dialer {
     return &dialer
 }
 
-// testProxyConnection 测试真实代理连接
-func testProxyConnection(node *parser.Node, timeout time.Duration) (int, error) {
-    switch node.Type {
-    case parser.ProxyTypeVLESS:
-        return testVLESSConnection(node, timeout)
-    case parser.ProxyTypeVMess:
-        return testVMessConnection(node, timeout)
-    case parser.ProxyTypeShadowsocks:
-        return testShadowsocksConnection(node, timeout)
-    default:
-        return -1, fmt.Errorf("不支持的协议类型: %s", node.Type)
+// testProxyConnection 测试真实代理连接，由节点类型对应的已注册协议完成探测
+// 内置协议经隧道请求 target 并将各阶段耗时记录到 timeline，第三方协议只记录总延迟
+func testProxyConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
+    protocol, ok := parser.LookupProtocol(node.Type)
+    if !ok {
+        return -1, unsupported(fmt.Errorf("不支持的协议类型: %s", node.Type))
     }
+    timeline.start()
+    if builtin, ok := protocol.(probingProtocol); ok {
+        return builtin.probe(ctx, node, timeout, target, timeline)
+    }
+    return protocol.Probe(ctx, node, timeout)
 }
 
-// testVLESSConnection 测试VLESS连接
-func testVLESSConnection(node *parser.Node, timeout time.Duration) (int, error) {
-    // 使用 HTTP 测试来验证代理是否真正可用
-    return testProxyWithHTTP(node, timeout)
+// probeFunc 单个协议的真实连接测试，经隧道请求 target，各阶段耗时记录到 timeline
+type probeFunc func(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error)
+
+// tunnelFunc 经节点建立到指定目标的隧道，各阶段耗时记录到 timeline
+type tunnelFunc func(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration, timeline *Timeline) (net.Conn, error)
+
+// probingProtocol 为 parser 中注册的内置协议挂载测试实现
+type probingProtocol struct {
+    parser.Protocol
+    probe         probeFunc
+    tunnel        tunnelFunc
+    handshakeOnly bool // probe 只完成握手，不请求探测地址
 }
 
-// testVMessConnection 测试VMess连接
-func testVMessConnection(node *parser.Node, timeout time.Duration) (int, error) {
-    address := net.JoinHostPort(node.Server, node.Port)
+func (p probingProtocol) Probe(ctx context.Context, node *parser.Node, timeout time.Duration) (int, error) {
+    return p.probe(ctx, node, timeout, defaultProbe, nil)
+}
 
-    // 使用直连 dialer 绕过系统代理
-    dialer := getDirectDialer(timeout)
+func (p probingProtocol) HandshakeOnly() bool {
+    return p.handshakeOnly
+}
 
-    var conn net.Conn
-    var err error
+func (p probingProtocol) DialTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration) (net.Conn, error) {
+    if p.tunnel == nil {
+        return nil, unsupported(fmt.Errorf("协议 %s 暂不支持测速", p.DisplayName()))
+    }
+    return p.tunnel(ctx, node, host, port, timeout, nil)
+}
 
-    // 准备 TLS 配置（如果需要）
-    var tlsConfig *tls.Config
-    if node.TLS {
-        tlsConfig = &tls.Config{
-            ServerName:         node.Server,
-            InsecureSkipVerify: true,
+func init() {
+    // Hysteria2/TUIC 仅验证 QUIC 握手，不提供隧道
+    for proxyType, impl := range map[parser.ProxyType]struct {
+        probe         probeFunc
+        tunnel        tunnelFunc
+        handshakeOnly bool
+    }{
+        parser.ProxyTypeVLESS:       {testVLESSConnection, dialVLESSTunnel, false},
+        parser.ProxyTypeVMess:       {testVMessConnection, dialVMessTunnel, false},
+        parser.ProxyTypeShadowsocks: {testShadowsocksConnection, dialShadowsocksTunnel, false},
+        parser.ProxyTypeTrojan:      {testTrojanConnection, dialTrojanTunnel, false},
+        parser.ProxyTypeHysteria2:   {testQUICConnection, nil, true},
+        parser.ProxyTypeTUIC:        {testQUICConnection, nil, true},
+    } {
+        // 只包装 parser 自带的实现：已被第三方实现替换的类型保持不变，
+        // 不论其注册先于还是晚于本包初始化
+        builtin, ok := parser.BuiltinProtocol(proxyType)
+        if !ok {
+            continue
         }
+        if current, _ := parser.LookupProtocol(proxyType); current != builtin {
+            continue
+        }
+        parser.RegisterProtocol(probingProtocol{Protocol: builtin, probe: impl.probe, tunnel: impl.tunnel, handshakeOnly: impl.handshakeOnly})
+    }
+}
+
+// dialTunnel 经节点建立到 host:port 的隧道，节点协议需实现 parser.TunnelDialer
+func dialTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration) (net.Conn, error) {
+    protocol, ok := parser.LookupProtocol(node.Type)
+    if !ok {
+        return nil, unsupported(fmt.Errorf("不支持的协议类型: %s", node.Type))
+    }
+    dialer, ok := protocol.(parser.TunnelDialer)
+    if !ok {
+        return nil, unsupported(fmt.Errorf("协议 %s 暂不支持测速", protocol.DisplayName()))
     }
+    return dialer.DialTunnel(ctx, node, host, port, timeout)
+}
+
+// isHandshakeOnly 判断节点协议的测试是否只完成握手，见 parser.HandshakeProber
+func isHandshakeOnly(node *parser.Node) bool {
+    protocol, ok := parser.LookupProtocol(node.Type)
+    if !ok {
+        return false
+    }
+    prober, ok := protocol.(parser.HandshakeProber)
+    return ok && prober.HandshakeOnly()
+}
 
+// testShadowsocksConnection 测试Shadowsocks连接
+// 使用节点的加密方式与密码完成 AEAD 握手，并经隧道请求探测地址
+// 只有收到可正确解密的有效响应才视为成功，密码或加密方式错误会显示为失败
+func testShadowsocksConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
     // 在网络操作正前方记录开始时间，确保只测量网络延迟
     start := time.Now()
 
-    if node.TLS {
-        // 使用直连 dialer，确保绕过系统代理
-        conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
-    } else {
-        // 使用直连 dialer，确保绕过系统代理
-        conn, err = dialer.Dial("tcp", address)
+    ss, err := dialShadowsocksTunnel(ctx, node, target.host, target.port, timeout, timeline)
+    if err != nil {
+        return -1, err
     }
+    defer ss.Close()
 
-    // 立即计算延迟，避免包含后续操作的时间
-    latency := time.Since(start).Milliseconds()
-
+    // 目标地址与探测请求一起发送
+    firstByte, err := probeTunnel(ss, target, timeline)
     if err != nil {
-        return -1, fmt.Errorf("VMess连接失败: %w", err)
+        return -1, atStage(StageHandshake, fmt.Errorf("Shadowsocks握手失败(密码或加密方式错误): %w", err))
     }
-    defer conn.Close()
 
-    // 只测试连接是否可达，不进行协议握手
-    // 因为完整的 VMess 协议需要复杂的加密和认证流程
-    // 简单的握手测试反而会导致连接被服务器拒绝
-    return int(latency), nil
+    return int(firstByte.Sub(start).Milliseconds()), nil
 }
 
-// testShadowsocksConnection 测试Shadowsocks连接
-func testShadowsocksConnection(node *parser.Node, timeout time.Duration) (int, error) {
-    address := net.JoinHostPort(node.Server, node.Port)
-
-    // 使用直连 dialer 绕过系统代理
-    dialer := getDirectDialer(timeout)
-
-    // 在网络操作正前方记录开始时间，确保只测量网络延迟
-    start := time.Now()
+// dialShadowsocksTunnel 建立到 host:port 的 Shadowsocks 隧道，目标地址在首次写入时发送
+func dialShadowsocksTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+    conn, err := dialServer(ctx, node, timeout, timeline)
+    if err != nil {
+        return nil, fmt.Errorf("Shadowsocks连接失败: %w", err)
+    }
 
-    conn, err := dialer.Dial("tcp", address)
+    conn.SetDeadline(time.Now().Add(timeout))
 
-    // 立即计算延迟，避免包含后续操作的时间
-    latency := time.Since(start).Milliseconds()
+    // 需要插件的节点必须通过插件握手，裸 TCP 可达并不代表客户端可用
+    if node.Plugin != "" {
+        wrapped, err := wrapPlugin(conn, node, timeout)
+        if err != nil {
+            conn.Close()
+            return nil, atStage(StageTransport, fmt.Errorf("Shadowsocks插件握手失败: %w", err))
+        }
+        conn = wrapped
+        timeline.mark(StageTransport)
+    }
 
+    ss, err := newSSConn(conn, node.Method, node.Password, host, port)
     if err != nil {
-        return -1, fmt.Errorf("Shadowsocks连接失败: %w", err)
+        conn.Close()
+        return nil, atStage(StageConfig, fmt.Errorf("Shadowsocks配置错误: %w", err))
     }
-    defer conn.Close()
-
-    // 只测试连接是否可达，不进行协议握手
-    // 因为 Shadowsocks 需要正确的加密和 SOCKS 封装
-    // 错误的握手（如 SOCKS5 握手）会导致连接被服务器拒绝
-    return int(latency), nil
+    return ss, nil
 }
 
-// testProxyWithHTTP 通过 HTTP 请求测试代理的真实可用性
-func testProxyWithHTTP(node *parser.Node, timeout time.Duration) (int, error) {
-    // 创建直连的 HTTP 客户端（绕过系统代理）
-    dialer := getDirectDialer(timeout)
-
-    // 构建到代理服务器的连接
+// dialServer 建立到节点服务器的直连 TCP 连接，记录 DNS 解析与 TCP 连接耗时
+// ctx 取消时中止拨号并关闭连接，其上各层阻塞中的读写随之返回
+func dialServer(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
     address := net.JoinHostPort(node.Server, node.Port)
 
-    var conn net.Conn
-    var err error
+    // 使用直连 dialer 绕过系统代理
+    dialer := getDirectDialer(timeout)
 
-    // 准备 TLS 配置（如果需要）
-    var tlsConfig *tls.Config
-    if node.TLS {
-        tlsConfig = &tls.Config{
-            ServerName:         node.Server,
-            InsecureSkipVerify: true,
+    // Control 在域名解析完成、发起连接前调用；多个地址并行尝试时只记录第一次
+    if timeline != nil && net.ParseIP(node.Server) == nil {
+        control := dialer.Control
+        var resolved sync.Once
+        dialer.Control = func(network, address string, c syscall.RawConn) error {
+            resolved.Do(func() { timeline.mark(StageDNS) })
+            return control(network, address, c)
         }
     }
 
-    // 在网络操作正前方记录开始时间，确保只测量网络延迟
-    start := time.Now()
-
-    // 根据是否 TLS 建立连接
-    if node.TLS {
-        conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
-    } else {
-        conn, err = dialer.Dial("tcp", address)
+    conn, err := dialer.DialContext(ctx, "tcp", address)
+    if err != nil {
+        return nil, atStage(StageConnect, err)
     }
+    timeline.mark(StageConnect)
+    return closeOnCancel(ctx, conn), nil
+}
 
-    // 立即计算延迟，避免包含后续操作的时间
-    latency := time.Since(start).Milliseconds()
-
+// dialNode 建立到节点的直连 TCP 连接，节点启用 TLS 时完成 TLS 握手
+// REALITY 节点完成 REALITY 握手，伪装站点的证书不会被视为成功
+func dialNode(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+    conn, err := dialServer(ctx, node, timeout, timeline)
     if err != nil {
-        return -1, fmt.Errorf("连接失败: %w", err)
+        return nil, err
+    }
+    if !node.TLS {
+        return conn, nil
     }
-    defer conn.Close()
 
-    // 设置连接超时，防止后续操作阻塞（虽然当前不做后续操作，但保留作为安全措施）
     conn.SetDeadline(time.Now().Add(timeout))
+    var secured net.Conn
+    if node.Security.Type == "reality" {
+        secured, err = dialReality(ctx, conn, node)
+    } else {
+        // TCP 连接与 TLS 握手分开进行，以便分别记录两个阶段的耗时
+        tlsConn := tls.Client(conn, newTLSConfig(node))
+        err = tlsConn.HandshakeContext(ctx)
+        secured = tlsConn
+    }
+    if err != nil {
+        conn.Close()
+        return nil, atStage(StageTLS, err)
+    }
+    timeline.mark(StageTLS)
+    return secured, nil
+}
+
+// newTLSConfig 根据节点声明的 SNI 与 ALPN 生成 TLS 配置
+// SNI 未声明时回退为服务器地址；默认校验证书，节点声明 allowInsecure 时跳过
+func newTLSConfig(node *parser.Node) *tls.Config {
+    return &tls.Config{
+        ServerName:         node.ServerName(),
+        NextProtos:         node.Security.ALPN,
+        InsecureSkipVerify: node.Security.AllowInsecure,
+    }
+}
 
-    // 简单的连通性测试：能建立连接即可
-    // 注意：这不是完整的代理协议实现，仅用于测速
-    // 注意：移除了无效的延迟检查，因为如果连接成功建立，延迟必然在超时范围内
+// cancelableConn 在 ctx 取消时被关闭的连接，正常关闭时注销取消回调
+type cancelableConn struct {
+    net.Conn
+    stop func() bool
+}
+
+// closeOnCancel 在 ctx 取消时关闭 conn，使阻塞中的读写立即返回
+func closeOnCancel(ctx context.Context, conn net.Conn) net.Conn {
+    stop := context.AfterFunc(ctx, func() { conn.Close() })
+    return &cancelableConn{Conn: conn, stop: stop}
+}
 
-    return int(latency), nil
+func (c *cancelableConn) Close() error {
+    c.stop()
+    return c.Conn.Close()
 }
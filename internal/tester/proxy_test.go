@@ -0,0 +1,67 @@
+package tester
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+// selfSignedCertificate 生成 host 的自签名证书
+func selfSignedCertificate(t *testing.T, host string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestTLSCertificateVerification(t *testing.T) {
+	config := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, "example.com")}}
+	port := startTCPServer(t, func(conn net.Conn) {
+		tlsConn := tls.Server(conn, config)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		serveVLESS(tlsConn, testUUID)
+	})
+
+	tests := []struct {
+		name          string
+		allowInsecure bool
+		want          Failure
+	}{
+		{"verify", false, FailureCertificate},
+		{"allow-insecure", true, FailureNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectFailure(t, &parser.Node{
+				Name:     tt.name,
+				Type:     parser.ProxyTypeVLESS,
+				Server:   "127.0.0.1",
+				Port:     port,
+				UUID:     testUUID,
+				TLS:      true,
+				Security: parser.Security{Type: "tls", SNI: "example.com", AllowInsecure: tt.allowInsecure},
+			}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package tester
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"proxy-tester/internal/parser"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"golang.org/x/crypto/blake2b"
+)
+
+// defaultQUICALPN Hysteria2 与 TUIC 默认使用的 ALPN
+const defaultQUICALPN = "h3"
+
+func init() {
+	// quic-go 在无法调整 UDP 缓冲区大小时会直接向标准输出打印警告，这会打乱进度条
+	os.Setenv("QUIC_GO_DISABLE_RECEIVE_BUFFER_WARNING", "true")
+}
+
+// testQUICConnection 测试基于 QUIC 的节点 (Hysteria2/TUIC)
+// 通过完成一次 QUIC + TLS 1.3 握手来衡量节点的 UDP 可达性与延迟
+// 不经节点请求探测地址，也不校验认证信息，结果标记为仅握手 (TestResult.HandshakeOnly)
+func testQUICConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
+	addr, err := resolveIPAddr(ctx, node.Server)
+	if err != nil {
+		return -1, atStage(StageDNS, fmt.Errorf("解析地址失败: %w", err))
+	}
+	port, err := net.DefaultResolver.LookupPort(ctx, "udp", node.Port)
+	if err != nil {
+		return -1, atStage(StageDNS, fmt.Errorf("解析端口失败: %w", err))
+	}
+	udpAddr := &net.UDPAddr{IP: addr.IP, Port: port, Zone: addr.Zone}
+	if net.ParseIP(node.Server) == nil {
+		timeline.mark(StageDNS)
+	}
+
+	udpConn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return -1, fmt.Errorf("创建UDP套接字失败: %w", err)
+	}
+	defer udpConn.Close()
+
+	var packetConn net.PacketConn = udpConn
+	if node.Obfs == "salamander" {
+		packetConn = newSalamanderConn(udpConn, node.ObfsPassword)
+	}
+
+	tlsConfig := newTLSConfig(node)
+	if len(tlsConfig.NextProtos) == 0 {
+		tlsConfig.NextProtos = []string{defaultQUICALPN}
+	}
+	quicConfig := &quic.Config{
+		HandshakeIdleTimeout: timeout,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 在网络操作正前方记录开始时间，确保只测量网络延迟
+	start := time.Now()
+
+	// quic.Dial 在握手完成后才返回
+	conn, err := quic.Dial(ctx, packetConn, udpAddr, tlsConfig, quicConfig)
+
+	// 立即计算延迟，避免包含后续操作的时间
+	latency := time.Since(start).Milliseconds()
+
+	if err != nil {
+		return -1, atStage(StageTLS, fmt.Errorf("QUIC握手失败: %w", err))
+	}
+	timeline.mark(StageTLS)
+	conn.CloseWithError(0, "")
+
+	return int(latency), nil
+}
+
+// salamanderConn 实现 Hysteria2 的 Salamander 混淆
+// 每个数据包格式: salt(8字节) + payload XOR BLAKE2b-256(password + salt)
+type salamanderConn struct {
+	net.PacketConn
+	key []byte
+}
+
+const salamanderSaltLen = 8
+
+func newSalamanderConn(conn net.PacketConn, password string) *salamanderConn {
+	return &salamanderConn{PacketConn: conn, key: []byte(password)}
+}
+
+func (c *salamanderConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	buf := make([]byte, len(p)+salamanderSaltLen)
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(buf)
+		if err != nil {
+			return 0, addr, err
+		}
+		// 丢弃长度不足的数据包
+		if n <= salamanderSaltLen {
+			continue
+		}
+		c.xor(p, buf[salamanderSaltLen:n], buf[:salamanderSaltLen])
+		return n - salamanderSaltLen, addr, nil
+	}
+}
+
+func (c *salamanderConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	buf := make([]byte, salamanderSaltLen+len(p))
+	if _, err := rand.Read(buf[:salamanderSaltLen]); err != nil {
+		return 0, err
+	}
+	c.xor(buf[salamanderSaltLen:], p, buf[:salamanderSaltLen])
+	if _, err := c.PacketConn.WriteTo(buf, addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// xor 使用由 salt 派生的密钥流对 src 做异或，结果写入 dst
+func (c *salamanderConn) xor(dst, src, salt []byte) {
+	hash := blake2b.Sum256(append(append([]byte{}, c.key...), salt...))
+	for i := range src {
+		dst[i] = src[i] ^ hash[i%len(hash)]
+	}
+}
@@ -0,0 +1,58 @@
+package tester
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+
+	"github.com/quic-go/quic-go"
+)
+
+// startQUICServer 启动使用自签名证书的本地 QUIC 测试服务器，只完成握手，返回监听端口
+func startQUICServer(t *testing.T) string {
+	t.Helper()
+	ln, err := quic.ListenAddr("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t, "example.com")},
+		NextProtos:   []string{defaultQUICALPN},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			if _, err := ln.Accept(context.Background()); err != nil {
+				return
+			}
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+func TestQUICCertificateVerification(t *testing.T) {
+	port := startQUICServer(t)
+
+	tests := []struct {
+		name string
+		link string
+		want Failure
+	}{
+		{"hy2-insecure", "hy2://auth@127.0.0.1:" + port + "?sni=example.com&insecure=1#hy2", FailureNone},
+		{"hy2-verify", "hy2://auth@127.0.0.1:" + port + "?sni=example.com#hy2", FailureCertificate},
+		{"tuic-insecure", "tuic://" + testUUID + ":pw@127.0.0.1:" + port + "?sni=example.com&allow_insecure=1#tuic", FailureNone},
+		{"tuic-verify", "tuic://" + testUUID + ":pw@127.0.0.1:" + port + "?sni=example.com#tuic", FailureCertificate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := parser.ParseNodes(tt.link, false)
+			if err != nil || len(nodes) != 1 {
+				t.Fatalf("解析链接失败: %v", err)
+			}
+			expectFailure(t, nodes[0], tt.want)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package tester
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"strings"
+	"time"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/hkdf"
+)
+
+// realityClientVersion 写入 Session ID 的客户端版本号 (对应 Xray 版本)
+var realityClientVersion = [3]byte{1, 8, 24}
+
+// errRealityUnverified 服务器证书不是由 REALITY 私钥签发，说明握手被转交给了伪装站点
+var errRealityUnverified = errors.New("REALITY认证失败: 服务器证书未通过校验(公钥或 short ID 错误)")
+
+// realityFingerprints uTLS 指纹名称与 ClientHello 模板的对应关系
+var realityFingerprints = map[string]utls.ClientHelloID{
+	"chrome":     utls.HelloChrome_Auto,
+	"firefox":    utls.HelloFirefox_Auto,
+	"safari":     utls.HelloSafari_Auto,
+	"ios":        utls.HelloIOS_Auto,
+	"android":    utls.HelloAndroid_11_OkHttp,
+	"edge":       utls.HelloEdge_Auto,
+	"360":        utls.Hello360_Auto,
+	"qq":         utls.HelloQQ_Auto,
+	"random":     utls.HelloRandomized,
+	"randomized": utls.HelloRandomized,
+}
+
+// dialReality 在已建立的 TCP 连接上完成 REALITY 客户端握手
+// 认证信息经 ECDH 派生的密钥加密后写入 ClientHello 的 Session ID；
+// 只有服务器返回由同一密钥签名的临时证书时才视为认证成功
+func dialReality(ctx context.Context, conn net.Conn, node *parser.Node) (net.Conn, error) {
+	publicKey, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(node.Security.PublicKey, "="))
+	if err != nil || len(publicKey) != 32 {
+		return nil, atStage(StageConfig, fmt.Errorf("无效的REALITY公钥: %q", node.Security.PublicKey))
+	}
+	serverKey, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, atStage(StageConfig, fmt.Errorf("无效的REALITY公钥: %w", err))
+	}
+
+	shortID, err := hex.DecodeString(node.Security.ShortID)
+	if err != nil || len(shortID) > 8 {
+		return nil, atStage(StageConfig, fmt.Errorf("无效的REALITY short ID: %q", node.Security.ShortID))
+	}
+
+	fingerprint := strings.ToLower(node.Security.Fingerprint)
+	if fingerprint == "" {
+		fingerprint = "chrome"
+	}
+	helloID, ok := realityFingerprints[fingerprint]
+	if !ok {
+		return nil, unsupported(fmt.Errorf("不支持的uTLS指纹: %s", node.Security.Fingerprint))
+	}
+
+	var authKey []byte
+	verified := false
+	config := &utls.Config{
+		ServerName:             node.ServerName(),
+		InsecureSkipVerify:     true,
+		SessionTicketsDisabled: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errRealityUnverified
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return errRealityUnverified
+			}
+			// REALITY 服务器签发的临时证书: 签名字段为 HMAC-SHA512(authKey, ed25519 公钥)
+			pub, ok := cert.PublicKey.(ed25519.PublicKey)
+			if !ok {
+				return errRealityUnverified
+			}
+			mac := hmac.New(sha512.New, authKey)
+			mac.Write(pub)
+			if !bytes.Equal(mac.Sum(nil), cert.Signature) {
+				return errRealityUnverified
+			}
+			verified = true
+			return nil
+		},
+	}
+
+	uconn := utls.UClient(conn, config, helloID)
+	if err := uconn.BuildHandshakeState(); err != nil {
+		return nil, fmt.Errorf("构造ClientHello失败: %w", err)
+	}
+
+	hello := uconn.HandshakeState.Hello
+	ecdheKey, ok := uconn.HandshakeState.State13.KeySharesParams.GetEcdheKey(utls.X25519)
+	if !ok {
+		return nil, fmt.Errorf("uTLS指纹 %s 未提供 X25519 密钥", fingerprint)
+	}
+
+	// 共享密钥经 HKDF 派生为认证密钥
+	authKey, err = ecdheKey.ECDH(serverKey)
+	if err != nil {
+		return nil, fmt.Errorf("REALITY密钥协商失败: %w", err)
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, authKey, hello.Random[:20], []byte("REALITY")), authKey); err != nil {
+		return nil, err
+	}
+
+	// Session ID 明文: 版本(3) + 保留(1) + 时间戳(4) + short ID(8)，
+	// 以 ClientHello 原文为附加数据加密，密文与认证标签共 32 字节
+	sessionID := make([]byte, 32)
+	copy(hello.Raw[39:], sessionID)
+	copy(sessionID, realityClientVersion[:])
+	binary.BigEndian.PutUint32(sessionID[4:], uint32(time.Now().Unix()))
+	copy(sessionID[8:], shortID)
+
+	block, err := aes.NewCipher(authKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	aead.Seal(sessionID[:0], hello.Random[20:], sessionID[:16], hello.Raw)
+	hello.SessionId = sessionID
+	// Session ID 在 ClientHello 原文中的固定偏移
+	copy(hello.Raw[39:], sessionID)
+
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, errRealityUnverified
+	}
+	return uconn, nil
+}
@@ -0,0 +1,142 @@
+package tester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+// testTimeout 测试中单次连接的超时时间
+const testTimeout = 2 * time.Second
+
+// startTCPServer 启动本地 TCP 测试服务器，每个连接交给 handle 处理，返回监听端口
+func startTCPServer(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	return port
+}
+
+// serveProbe 模拟探测目标：读取隧道内的探测请求并返回 204
+func serveProbe(rw io.ReadWriter) error {
+	req, err := http.ReadRequest(bufio.NewReader(rw))
+	if err != nil {
+		return err
+	}
+	req.Body.Close()
+	_, err = io.WriteString(rw, "HTTP/1.1 204 No Content\r\n\r\n")
+	return err
+}
+
+// testUUID 测试服务器使用的 UUID
+const testUUID = "b831381d-6324-4d53-ad4f-8cda48b30811"
+
+// vlessServerConn VLESS 测试服务器端连接，首次写入时附带响应头
+type vlessServerConn struct {
+	io.ReadWriter
+	headerSent bool
+}
+
+func (c *vlessServerConn) Write(p []byte) (int, error) {
+	if !c.headerSent {
+		c.headerSent = true
+		if _, err := c.ReadWriter.Write(append([]byte{vlessVersion, 0}, p...)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return c.ReadWriter.Write(p)
+}
+
+// serveVLESS 在已建立的传输层上模拟 VLESS 服务器：校验请求头中的 UUID 后响应探测请求
+// UUID 不符时与真实服务器一样不返回任何数据
+func serveVLESS(rw io.ReadWriter, uuid string) error {
+	conn, err := acceptVLESS(rw, uuid)
+	if err != nil {
+		return err
+	}
+	return serveProbe(conn)
+}
+
+// acceptVLESS 读取并校验 VLESS 请求头，返回承载隧道数据的服务器端连接
+func acceptVLESS(rw io.ReadWriter, uuid string) (io.ReadWriter, error) {
+	id, err := parseUUID(uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(rw)
+	header := make([]byte, 1+16+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[0] != vlessVersion || !bytes.Equal(header[1:17], id[:]) {
+		return nil, errors.New("无效的 VLESS 请求头")
+	}
+	// Addons Cmd Port Atyp
+	addr := make([]byte, int(header[17])+1+2+1)
+	if _, err := io.ReadFull(reader, addr); err != nil {
+		return nil, err
+	}
+	var size int
+	switch addr[len(addr)-1] {
+	case vlessAddrIPv4:
+		size = 4
+	case vlessAddrIPv6:
+		size = 16
+	case vlessAddrDomain:
+		length, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size = int(length)
+	}
+	if _, err := reader.Discard(size); err != nil {
+		return nil, err
+	}
+
+	return &vlessServerConn{ReadWriter: struct {
+		io.Reader
+		io.Writer
+	}{reader, rw}}, nil
+}
+
+// probeNode 对节点进行一次真实连接测试，返回失败原因与错误
+func probeNode(t *testing.T, node *parser.Node) (Failure, error) {
+	t.Helper()
+	_, err := testProxyConnection(context.Background(), node, testTimeout, defaultProbe, nil)
+	return classifyError(err), err
+}
+
+// expectFailure 断言节点测试的失败原因
+func expectFailure(t *testing.T, node *parser.Node, want Failure) {
+	t.Helper()
+	got, err := probeNode(t, node)
+	if got != want {
+		t.Errorf("%s: 失败原因为 %s (%v)，期望 %s", node.Name, got, err, want)
+	}
+}
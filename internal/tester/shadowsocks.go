@@ -0,0 +1,367 @@
+package tester
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+	"lukechampine.com/blake3"
+)
+
+// Shadowsocks AEAD 数据块的最大负载长度
+const (
+	ssMaxPayload     = 0x3fff // SIP004
+	ss2022MaxPayload = 0xffff // SIP022
+)
+
+// SIP022 头部类型与时间戳容差
+const (
+	ss2022HeaderClient = 0
+	ss2022HeaderServer = 1
+	ss2022MaxTimeDiff  = 30 * time.Second
+)
+
+// ssCipher Shadowsocks AEAD 加密方式
+type ssCipher struct {
+	keySize  int
+	saltSize int
+	newAEAD  func(key []byte) (cipher.AEAD, error)
+	is2022   bool // SIP022 (2022-blake3-*) 加密方式
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ssCiphers 支持的 Shadowsocks 加密方式
+var ssCiphers = map[string]*ssCipher{
+	"aes-128-gcm":                   {keySize: 16, saltSize: 16, newAEAD: newAESGCM},
+	"aes-192-gcm":                   {keySize: 24, saltSize: 24, newAEAD: newAESGCM},
+	"aes-256-gcm":                   {keySize: 32, saltSize: 32, newAEAD: newAESGCM},
+	"chacha20-ietf-poly1305":        {keySize: 32, saltSize: 32, newAEAD: chacha20poly1305.New},
+	"chacha20-poly1305":             {keySize: 32, saltSize: 32, newAEAD: chacha20poly1305.New},
+	"xchacha20-ietf-poly1305":       {keySize: 32, saltSize: 32, newAEAD: chacha20poly1305.NewX},
+	"2022-blake3-aes-128-gcm":       {keySize: 16, saltSize: 16, newAEAD: newAESGCM, is2022: true},
+	"2022-blake3-aes-256-gcm":       {keySize: 32, saltSize: 32, newAEAD: newAESGCM, is2022: true},
+	"2022-blake3-chacha20-poly1305": {keySize: 32, saltSize: 32, newAEAD: chacha20poly1305.New, is2022: true},
+}
+
+// ssConn Shadowsocks AEAD 客户端连接
+// 首次写入时发送盐与目标地址，首次读取时校验服务器返回的盐（SIP022 还会校验响应头）
+type ssConn struct {
+	net.Conn
+	cipher *ssCipher
+
+	key          []byte   // 旧版为主密钥，SIP022 为用户 PSK
+	identityKeys [][]byte // SIP022 多用户模式下的 iPSK 列表
+	target       []byte   // SOCKS5 格式的目标地址
+
+	writer      cipher.AEAD
+	writeNonce  []byte
+	reader      cipher.AEAD
+	readNonce   []byte
+	requestSalt []byte
+
+	pending []byte // 已解密但尚未被读取的数据
+}
+
+// newSSConn 创建 Shadowsocks AEAD 客户端连接
+func newSSConn(conn net.Conn, method string, password string, host string, port int) (*ssConn, error) {
+	ssc, ok := ssCiphers[strings.ToLower(method)]
+	if !ok {
+		return nil, unsupported(fmt.Errorf("不支持的加密方式: %s", method))
+	}
+
+	c := &ssConn{
+		Conn:   conn,
+		cipher: ssc,
+		target: encodeSocksAddr(host, port),
+	}
+
+	if !ssc.is2022 {
+		c.key = evpBytesToKey(password, ssc.keySize)
+		return c, nil
+	}
+
+	// SIP022 的密码为 Base64 编码的 PSK，多用户模式下以冒号分隔 iPSK 与 uPSK
+	var keys [][]byte
+	for _, encoded := range strings.Split(password, ":") {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 2022 密钥: %w", err)
+		}
+		if len(key) != ssc.keySize {
+			return nil, fmt.Errorf("无效的 2022 密钥长度: %d，应为 %d", len(key), ssc.keySize)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > 1 && strings.Contains(method, "chacha20") {
+		return nil, fmt.Errorf("%s 不支持多用户 (EIH) 模式", method)
+	}
+	c.key = keys[len(keys)-1]
+	c.identityKeys = keys[:len(keys)-1]
+	return c, nil
+}
+
+// evpBytesToKey 使用 OpenSSL EVP_BytesToKey (MD5) 从密码派生主密钥
+func evpBytesToKey(password string, keySize int) []byte {
+	var key, prev []byte
+	for len(key) < keySize {
+		h := md5.New()
+		h.Write(prev)
+		h.Write([]byte(password))
+		prev = h.Sum(nil)
+		key = append(key, prev...)
+	}
+	return key[:keySize]
+}
+
+// sessionAEAD 根据盐派生会话子密钥并创建 AEAD
+func (c *ssConn) sessionAEAD(salt []byte) (cipher.AEAD, error) {
+	subkey := make([]byte, c.cipher.keySize)
+	if c.cipher.is2022 {
+		blake3.DeriveKey(subkey, "shadowsocks 2022 session subkey", append(append([]byte{}, c.key...), salt...))
+	} else {
+		r := hkdf.New(sha1.New, c.key, salt, []byte("ss-subkey"))
+		if _, err := io.ReadFull(r, subkey); err != nil {
+			return nil, err
+		}
+	}
+	return c.cipher.newAEAD(subkey)
+}
+
+// seal 加密一个数据块并递增 nonce
+func seal(aead cipher.AEAD, nonce []byte, dst []byte, plaintext []byte) []byte {
+	dst = aead.Seal(dst, nonce, plaintext, nil)
+	incrementNonce(nonce)
+	return dst
+}
+
+// open 解密一个数据块并递增 nonce
+func open(aead cipher.AEAD, nonce []byte, ciphertext []byte) ([]byte, error) {
+	plaintext, err := aead.Open(ciphertext[:0], nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	incrementNonce(nonce)
+	return plaintext, nil
+}
+
+// incrementNonce 以小端序递增 nonce
+func incrementNonce(nonce []byte) {
+	for i := range nonce {
+		nonce[i]++
+		if nonce[i] != 0 {
+			return
+		}
+	}
+}
+
+func (c *ssConn) Write(p []byte) (int, error) {
+	var out []byte
+	payload := p
+
+	if c.writer == nil {
+		salt := make([]byte, c.cipher.saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return 0, err
+		}
+		writer, err := c.sessionAEAD(salt)
+		if err != nil {
+			return 0, err
+		}
+		c.writer = writer
+		c.writeNonce = make([]byte, writer.NonceSize())
+		c.requestSalt = salt
+		out = append(out, salt...)
+
+		if c.cipher.is2022 {
+			header, err := c.requestHeader2022(salt, &payload)
+			if err != nil {
+				return 0, err
+			}
+			out = append(out, header...)
+		} else {
+			// 目标地址作为第一个数据块的开头发送
+			payload = append(append([]byte{}, c.target...), p...)
+		}
+	}
+
+	maxPayload := ssMaxPayload
+	if c.cipher.is2022 {
+		maxPayload = ss2022MaxPayload
+	}
+	for len(payload) > 0 {
+		chunk := payload
+		if len(chunk) > maxPayload {
+			chunk = chunk[:maxPayload]
+		}
+		out = seal(c.writer, c.writeNonce, out, binary.BigEndian.AppendUint16(nil, uint16(len(chunk))))
+		out = seal(c.writer, c.writeNonce, out, chunk)
+		payload = payload[len(chunk):]
+	}
+
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// requestHeader2022 构造 SIP022 请求头: [EIH] + 固定长度头 + 可变长度头
+// 尽可能多的首包数据会随可变长度头发送，剩余部分通过 payload 返回
+func (c *ssConn) requestHeader2022(salt []byte, payload *[]byte) ([]byte, error) {
+	var out []byte
+
+	// 多用户模式下的身份头 (Extensible Identity Headers)
+	for i, identityKey := range c.identityKeys {
+		subkey := make([]byte, c.cipher.keySize)
+		blake3.DeriveKey(subkey, "shadowsocks 2022 identity subkey", append(append([]byte{}, identityKey...), salt...))
+		block, err := aes.NewCipher(subkey)
+		if err != nil {
+			return nil, err
+		}
+		nextKey := c.key
+		if i+1 < len(c.identityKeys) {
+			nextKey = c.identityKeys[i+1]
+		}
+		hash := blake3.Sum256(nextKey)
+		header := make([]byte, aes.BlockSize)
+		block.Encrypt(header, hash[:aes.BlockSize])
+		out = append(out, header...)
+	}
+
+	first := *payload
+	maxFirst := ss2022MaxPayload - len(c.target) - 2
+	if len(first) > maxFirst {
+		first = first[:maxFirst]
+	}
+	*payload = (*payload)[len(first):]
+
+	// 可变长度头: 目标地址 + 填充长度 + 填充 + 首包数据，没有首包数据时必须填充
+	variable := append([]byte{}, c.target...)
+	var padding int
+	if len(first) == 0 {
+		var b [1]byte
+		rand.Read(b[:])
+		padding = 16 + int(b[0])
+	}
+	variable = binary.BigEndian.AppendUint16(variable, uint16(padding))
+	variable = append(variable, make([]byte, padding)...)
+	variable = append(variable, first...)
+
+	// 固定长度头: 类型 + 时间戳 + 可变长度头长度
+	fixed := []byte{ss2022HeaderClient}
+	fixed = binary.BigEndian.AppendUint64(fixed, uint64(time.Now().Unix()))
+	fixed = binary.BigEndian.AppendUint16(fixed, uint16(len(variable)))
+
+	out = seal(c.writer, c.writeNonce, out, fixed)
+	out = seal(c.writer, c.writeNonce, out, variable)
+	return out, nil
+}
+
+func (c *ssConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if err := c.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// readChunk 读取并解密下一个数据块，首次调用时处理服务器的盐与响应头
+func (c *ssConn) readChunk() error {
+	if c.reader == nil {
+		salt := make([]byte, c.cipher.saltSize)
+		if _, err := io.ReadFull(c.Conn, salt); err != nil {
+			return atStage(StageHandshake, fmt.Errorf("读取服务器响应失败: %w", err))
+		}
+		reader, err := c.sessionAEAD(salt)
+		if err != nil {
+			return err
+		}
+		c.reader = reader
+		c.readNonce = make([]byte, reader.NonceSize())
+
+		if c.cipher.is2022 {
+			length, err := c.readResponseHeader2022()
+			if err != nil {
+				return atStage(StageHandshake, err)
+			}
+			return c.readPayload(length)
+		}
+	}
+
+	lengthChunk := make([]byte, 2+c.reader.Overhead())
+	if _, err := io.ReadFull(c.Conn, lengthChunk); err != nil {
+		return err
+	}
+	plain, err := open(c.reader, c.readNonce, lengthChunk)
+	if err != nil {
+		return atStage(StageHandshake, fmt.Errorf("解密失败(密码或加密方式错误): %w", err))
+	}
+
+	length := int(binary.BigEndian.Uint16(plain))
+	if !c.cipher.is2022 {
+		length &= ssMaxPayload
+	}
+	return c.readPayload(length)
+}
+
+// readPayload 读取并解密指定长度的负载数据块
+func (c *ssConn) readPayload(length int) error {
+	chunk := make([]byte, length+c.reader.Overhead())
+	if _, err := io.ReadFull(c.Conn, chunk); err != nil {
+		return err
+	}
+	plain, err := open(c.reader, c.readNonce, chunk)
+	if err != nil {
+		return atStage(StageHandshake, fmt.Errorf("解密失败(密码或加密方式错误): %w", err))
+	}
+	c.pending = plain
+	return nil
+}
+
+// readResponseHeader2022 读取并校验 SIP022 响应头，返回第一个负载数据块的长度
+// 响应头格式: 类型 + 时间戳 + 请求盐 + 长度
+func (c *ssConn) readResponseHeader2022() (int, error) {
+	headerLen := 1 + 8 + c.cipher.saltSize + 2
+	chunk := make([]byte, headerLen+c.reader.Overhead())
+	if _, err := io.ReadFull(c.Conn, chunk); err != nil {
+		return 0, fmt.Errorf("读取响应头失败: %w", err)
+	}
+	header, err := open(c.reader, c.readNonce, chunk)
+	if err != nil {
+		return 0, fmt.Errorf("解密失败(密码或加密方式错误): %w", err)
+	}
+
+	if header[0] != ss2022HeaderServer {
+		return 0, fmt.Errorf("无效的响应头类型: %d", header[0])
+	}
+	timestamp := time.Unix(int64(binary.BigEndian.Uint64(header[1:9])), 0)
+	if diff := time.Since(timestamp); diff > ss2022MaxTimeDiff || diff < -ss2022MaxTimeDiff {
+		return 0, fmt.Errorf("响应时间戳偏差过大: %v", diff)
+	}
+	if !bytes.Equal(header[9:9+c.cipher.saltSize], c.requestSalt) {
+		return 0, fmt.Errorf("响应中的请求盐不匹配")
+	}
+
+	return int(binary.BigEndian.Uint16(header[9+c.cipher.saltSize:])), nil
+}
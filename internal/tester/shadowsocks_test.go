@@ -0,0 +1,211 @@
+package tester
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+// ssServerConn Shadowsocks 测试服务器端连接，复用客户端的密钥派生与分块加密
+type ssServerConn struct {
+	net.Conn
+	session *ssConn // 提供服务器配置的加密方式与密钥
+
+	reader      cipher.AEAD
+	readNonce   []byte
+	writer      cipher.AEAD
+	writeNonce  []byte
+	requestSalt []byte
+	pending     []byte
+
+	wrongSalt bool // SIP022 响应头回显错误的请求盐
+}
+
+// handshake 解密客户端的盐与请求头，密钥不符时返回错误
+func (s *ssServerConn) handshake() error {
+	salt := make([]byte, s.session.cipher.saltSize)
+	if _, err := io.ReadFull(s.Conn, salt); err != nil {
+		return err
+	}
+	reader, err := s.session.sessionAEAD(salt)
+	if err != nil {
+		return err
+	}
+	s.reader, s.readNonce, s.requestSalt = reader, make([]byte, reader.NonceSize()), salt
+
+	if !s.session.cipher.is2022 {
+		first, err := s.readChunk()
+		if err != nil {
+			return err
+		}
+		s.pending, err = skipSocksAddr(first)
+		return err
+	}
+
+	fixed, err := s.readSealed(1 + 8 + 2)
+	if err != nil {
+		return err
+	}
+	if fixed[0] != ss2022HeaderClient {
+		return errors.New("无效的请求头类型")
+	}
+	variable, err := s.readSealed(int(binary.BigEndian.Uint16(fixed[9:])))
+	if err != nil {
+		return err
+	}
+	rest, err := skipSocksAddr(variable)
+	if err != nil || len(rest) < 2 {
+		return errors.New("无效的可变长度头")
+	}
+	s.pending = rest[2+int(binary.BigEndian.Uint16(rest)):]
+	return nil
+}
+
+// readSealed 读取并解密一个长度为 size 的数据块
+func (s *ssServerConn) readSealed(size int) ([]byte, error) {
+	buf := make([]byte, size+s.reader.Overhead())
+	if _, err := io.ReadFull(s.Conn, buf); err != nil {
+		return nil, err
+	}
+	return open(s.reader, s.readNonce, buf)
+}
+
+// readChunk 读取长度块与负载块
+func (s *ssServerConn) readChunk() ([]byte, error) {
+	length, err := s.readSealed(2)
+	if err != nil {
+		return nil, err
+	}
+	return s.readSealed(int(binary.BigEndian.Uint16(length)))
+}
+
+func (s *ssServerConn) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		chunk, err := s.readChunk()
+		if err != nil {
+			return 0, err
+		}
+		s.pending = chunk
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *ssServerConn) Write(p []byte) (int, error) {
+	var out []byte
+	if s.writer == nil {
+		salt := make([]byte, s.session.cipher.saltSize)
+		rand.Read(salt)
+		writer, err := s.session.sessionAEAD(salt)
+		if err != nil {
+			return 0, err
+		}
+		s.writer, s.writeNonce = writer, make([]byte, writer.NonceSize())
+		out = append(out, salt...)
+
+		if s.session.cipher.is2022 {
+			requestSalt := s.requestSalt
+			if s.wrongSalt {
+				requestSalt = make([]byte, len(requestSalt))
+			}
+			header := []byte{ss2022HeaderServer}
+			header = binary.BigEndian.AppendUint64(header, uint64(time.Now().Unix()))
+			header = append(header, requestSalt...)
+			header = binary.BigEndian.AppendUint16(header, uint16(len(p)))
+			out = seal(s.writer, s.writeNonce, out, header)
+			out = seal(s.writer, s.writeNonce, out, p)
+			_, err := s.Conn.Write(out)
+			return len(p), err
+		}
+	}
+	out = seal(s.writer, s.writeNonce, out, binary.BigEndian.AppendUint16(nil, uint16(len(p))))
+	out = seal(s.writer, s.writeNonce, out, p)
+	_, err := s.Conn.Write(out)
+	return len(p), err
+}
+
+// skipSocksAddr 跳过 SOCKS5 格式的目标地址，返回其后的数据
+func skipSocksAddr(b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var size int
+	switch b[0] {
+	case 0x01:
+		size = 1 + 4
+	case 0x04:
+		size = 1 + 16
+	case 0x03:
+		if len(b) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		size = 2 + int(b[1])
+	default:
+		return nil, errors.New("无效的地址类型")
+	}
+	if len(b) < size+2 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b[size+2:], nil
+}
+
+// startSSServer 启动使用指定加密方式与密码的 Shadowsocks 测试服务器
+// 密钥不符时与真实服务器一样不返回任何数据，直接关闭连接
+func startSSServer(t *testing.T, method, password string, wrongSalt bool) string {
+	return startTCPServer(t, func(conn net.Conn) {
+		session, err := newSSConn(conn, method, password, "", 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		server := &ssServerConn{Conn: conn, session: session, wrongSalt: wrongSalt}
+		if err := server.handshake(); err != nil {
+			return
+		}
+		serveProbe(server)
+	})
+}
+
+func TestShadowsocksHandshake(t *testing.T) {
+	const psk128 = "AAECAwQFBgcICQoLDA0ODw=="
+	const otherPSK128 = "Dw4NDAsKCQgHBgUEAwIBAA=="
+
+	tests := []struct {
+		name           string
+		serverMethod   string
+		serverPassword string
+		method         string
+		password       string
+		wrongSalt      bool
+		want           Failure
+	}{
+		{"aead", "aes-128-gcm", "pw", "aes-128-gcm", "pw", false, FailureNone},
+		{"aead-chacha20", "chacha20-ietf-poly1305", "pw", "chacha20-ietf-poly1305", "pw", false, FailureNone},
+		{"aead-wrong-password", "aes-128-gcm", "pw", "aes-128-gcm", "wrong", false, FailureAuthRejected},
+		{"aead-wrong-method", "aes-256-gcm", "pw", "chacha20-ietf-poly1305", "pw", false, FailureAuthRejected},
+		{"2022", "2022-blake3-aes-128-gcm", psk128, "2022-blake3-aes-128-gcm", psk128, false, FailureNone},
+		{"2022-wrong-key", "2022-blake3-aes-128-gcm", psk128, "2022-blake3-aes-128-gcm", otherPSK128, false, FailureAuthRejected},
+		{"2022-wrong-method", "2022-blake3-aes-128-gcm", psk128, "aes-128-gcm", psk128, false, FailureAuthRejected},
+		{"2022-response-salt-mismatch", "2022-blake3-aes-128-gcm", psk128, "2022-blake3-aes-128-gcm", psk128, true, FailureAuthRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startSSServer(t, tt.serverMethod, tt.serverPassword, tt.wrongSalt)
+			expectFailure(t, &parser.Node{
+				Name:     tt.name,
+				Type:     parser.ProxyTypeShadowsocks,
+				Server:   "127.0.0.1",
+				Port:     port,
+				Method:   tt.method,
+				Password: tt.password,
+			}, tt.want)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package tester
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"sync"
+	"time"
+)
+
+// DefaultSpeedURL 默认测速下载地址
+const DefaultSpeedURL = "https://speed.cloudflare.com/__down?bytes=100000000"
+
+// DefaultUploadURL 默认上传测速地址，接收并丢弃请求体
+const DefaultUploadURL = "https://speed.cloudflare.com/__up"
+
+// speedConfig 测速配置
+type speedConfig struct {
+	download     *probeTarget  // 下载测速地址，nil 表示不进行下载测速
+	downloadTime time.Duration // 最长下载时间
+	maxBytes     int64         // 最多下载字节数，0 表示不限
+
+	upload      *probeTarget  // 上传测速地址，nil 表示不进行上传测速
+	uploadTime  time.Duration // 最长上传时间
+	uploadBytes int64         // 上传数据量
+}
+
+// SetSpeedTest 启用下载测速：经隧道下载 rawURL，达到 duration 时长或 maxBytes 字节 (0 表示不限) 即停止
+func (o *Options) SetSpeedTest(rawURL string, duration time.Duration, maxBytes int64) error {
+	target, err := newProbeTarget(rawURL, http.MethodGet, http.StatusOK, 0)
+	if err != nil {
+		return fmt.Errorf("无效的测速地址: %w", err)
+	}
+	if duration <= 0 {
+		return fmt.Errorf("无效的测速时长: %s", duration)
+	}
+	if maxBytes < 0 {
+		return fmt.Errorf("无效的测速数据量: %d", maxBytes)
+	}
+	o.speed.download = target
+	o.speed.downloadTime = duration
+	o.speed.maxBytes = maxBytes
+	return nil
+}
+
+// SetUploadTest 启用上传测速：经隧道向 rawURL 发送 size 字节的 POST 请求体，最长 duration 时长
+func (o *Options) SetUploadTest(rawURL string, duration time.Duration, size int64) error {
+	if duration <= 0 {
+		return fmt.Errorf("无效的测速时长: %s", duration)
+	}
+	if size <= 0 {
+		return fmt.Errorf("无效的上传数据量: %d", size)
+	}
+	// 接收端返回任意 2xx 状态码均视为成功，状态码由 uploadSpeed 校验
+	target, err := newProbeTarget(rawURL, http.MethodPost, 0, size)
+	if err != nil {
+		return fmt.Errorf("无效的上传测速地址: %w", err)
+	}
+	o.speed.upload = target
+	o.speed.uploadTime = duration
+	o.speed.uploadBytes = size
+	return nil
+}
+
+// TestSpeed 对连接测试成功的节点进行 opts 中已启用的下载/上传测速，结果写入 DownloadSpeed 与 UploadSpeed
+// 测速占用带宽，使用独立的并发数以免节点之间相互挤占；
+// ctx 取消后不再派发新的节点，被中止的测速不写入结果；ctx 超出截止时间时未完成的测速标记为跳过
+// 每个节点的测速与连接测试共用 opts 的节点时限，opts 应与 TestNodes 使用的相同
+func TestSpeed(ctx context.Context, results []*TestResult, concurrency int, timeoutSec int, opts *Options) {
+	if opts == nil || (opts.speed.download == nil && opts.speed.upload == nil) {
+		return
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if timeoutSec <= 0 {
+		timeoutSec = 30
+	}
+	timeout := time.Duration(timeoutSec) * time.Second
+
+	targets := make([]*TestResult, 0, len(results))
+	for _, r := range results {
+		if r.IsSuccess() {
+			targets = append(targets, r)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	bar := newProgressBar(len(targets), "🚀 测速")
+
+dispatch:
+	for i, result := range targets {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			for _, r := range targets[i:] {
+				skipSpeed(r, &opts.speed, deadlineError(ctx, ctx))
+			}
+			bar.Add(len(targets) - i)
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(r *TestResult) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			testNodeSpeed(ctx, r, timeout, opts)
+			bar.Add(1)
+		}(result)
+	}
+
+	wg.Wait()
+	bar.Finish()
+	fmt.Println()
+}
+
+// testNodeSpeed 依次进行下载与上传测速，使用节点时限在连接测试后剩余的时间
+// 失败时保留错误链，与连接测试一样按阶段分类 (见 TestResult.DownloadFailure)；
+// 已完成的测速即使之后被取消也会保留，只有被中止的测速不写入结果
+func testNodeSpeed(ctx context.Context, r *TestResult, timeout time.Duration, opts *Options) {
+	runCtx := ctx
+	if opts.nodeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.nodeBudget-r.elapsed)
+		defer cancel()
+	}
+
+	if opts.speed.download != nil {
+		mbps, err := downloadSpeed(ctx, r.Node, timeout, &opts.speed)
+		if err != nil && ctx.Err() != nil {
+			skipSpeed(r, &opts.speed, deadlineError(runCtx, ctx))
+			return
+		}
+		r.DownloadError = err
+		r.DownloadSpeed = mbps
+	}
+	if opts.speed.upload != nil {
+		mbps, err := uploadSpeed(ctx, r.Node, timeout, &opts.speed)
+		if err != nil && ctx.Err() != nil {
+			skipSpeed(r, &opts.speed, deadlineError(runCtx, ctx))
+			return
+		}
+		r.UploadError = err
+		r.UploadSpeed = mbps
+	}
+}
+
+// skipSpeed 将 config 中已启用但尚未完成的测速标记为跳过，err 为 deadlineError 返回的跳过原因
+// err 为 nil (如用户中断) 时保持未测速
+func skipSpeed(r *TestResult, config *speedConfig, err error) {
+	if err == nil {
+		return
+	}
+	if config.download != nil && r.DownloadSpeed < 0 && r.DownloadError == nil {
+		r.DownloadError = err
+	}
+	if config.upload != nil && r.UploadSpeed < 0 && r.UploadError == nil {
+		r.UploadError = err
+	}
+}
+
+// downloadSpeed 经节点下载 config 中的测速地址，返回下载速度 (Mbps)
+// 计时从收到响应头开始，到达时长或数据量上限时停止；
+// 已收到数据后因超时中断视为正常结束
+func downloadSpeed(ctx context.Context, node *parser.Node, timeout time.Duration, config *speedConfig) (float64, error) {
+	target := config.download
+	conn, err := dialTunnel(ctx, node, target.host, target.port, timeout)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	resp, _, err := target.send(conn, StageProbe)
+	if err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("测速请求失败: %w", err))
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(config.downloadTime))
+
+	var body io.Reader = resp.Body
+	if config.maxBytes > 0 {
+		body = io.LimitReader(body, config.maxBytes)
+	}
+	n, err := io.Copy(io.Discard, body)
+	elapsed := time.Since(start)
+
+	// 被 ctx 取消而中断的下载不是完整的测量，已正常结束的下载则不受之后的取消影响
+	if err != nil && ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+	if n == 0 {
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		return -1, atStage(StageProbe, fmt.Errorf("下载测速失败: %w", err))
+	}
+	return mbps(n, elapsed), nil
+}
+
+// uploadSpeed 经节点向 config 中的上传测速地址发送请求体，返回上传速度 (Mbps)
+// 计时从开始发送请求体到收到响应头为止，响应在接收端读完请求体后才会返回；
+// 到达时长上限时按已发送的数据量计算
+func uploadSpeed(ctx context.Context, node *parser.Node, timeout time.Duration, config *speedConfig) (float64, error) {
+	target := config.upload
+	tunnel, err := dialTunnel(ctx, node, target.host, target.port, timeout)
+	if err != nil {
+		return -1, err
+	}
+	defer tunnel.Close()
+
+	conn, err := target.open(tunnel, StageProbe)
+	if err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("上传测速请求失败: %w", err))
+	}
+	// 协议请求头与 HTTP 请求头一起发送
+	if _, err := conn.Write(target.request); err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("上传测速请求失败: %w", err))
+	}
+
+	start := time.Now()
+	deadline := start.Add(config.uploadTime)
+	tunnel.SetDeadline(deadline)
+
+	// 传输层在超时后可能以其他错误结束 (如 HTTP/2 流被关闭)，因此按时间判断是否到达上限
+	sent, err := writePayload(conn, config.uploadBytes)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: target.method}); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				return -1, atStage(StageProbe, fmt.Errorf("上传测速响应状态码错误: %d", resp.StatusCode))
+			}
+			return mbps(sent, time.Since(start)), nil
+		}
+	}
+	if ctx.Err() != nil {
+		return -1, ctx.Err()
+	}
+	if sent > 0 && !time.Now().Before(deadline) {
+		return mbps(sent, time.Since(start)), nil
+	}
+	return -1, atStage(StageProbe, fmt.Errorf("上传测速失败: %w", err))
+}
+
+// writePayload 向连接写入 size 字节的填充数据，返回已写入的字节数
+func writePayload(conn net.Conn, size int64) (int64, error) {
+	chunk := make([]byte, 32<<10)
+	var sent int64
+	for sent < size {
+		n := min(int64(len(chunk)), size-sent)
+		if _, err := conn.Write(chunk[:n]); err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return sent, nil
+}
+
+// mbps 计算传输速率 (Mbps)
+func mbps(bytes int64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		elapsed = time.Millisecond
+	}
+	return float64(bytes) * 8 / elapsed.Seconds() / 1e6
+}
@@ -0,0 +1,87 @@
+package tester
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+func TestSpeedFailureClassified(t *testing.T) {
+	t.Parallel()
+	// 测速地址期望 200，测试服务器返回 204
+	opts := NewOptions()
+	if err := opts.SetSpeedTest("http://speed.example.com/", time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+	port := startTCPServer(t, func(conn net.Conn) {
+		serveVLESS(conn, testUUID)
+	})
+
+	result := newTestResult(&parser.Node{
+		Name:   "speed",
+		Type:   parser.ProxyTypeVLESS,
+		Server: "127.0.0.1",
+		Port:   port,
+		UUID:   testUUID,
+	})
+	testNodeSpeed(context.Background(), result, testTimeout, opts)
+	if result.DownloadSpeed >= 0 || result.DownloadError == nil {
+		t.Fatalf("下载测速应失败: %.1fMbps", result.DownloadSpeed)
+	}
+	if got := result.DownloadFailure(); got != FailureProbeHTTP {
+		t.Errorf("失败原因为 %s (%v)，期望 %s", got, result.DownloadError, FailureProbeHTTP)
+	}
+}
+
+// 节点时限在上传测速中途到达时，保留已完成的下载测速，上传标记为跳过
+func TestSpeedNodeBudget(t *testing.T) {
+	t.Parallel()
+	opts := NewOptions()
+	if err := opts.SetSpeedTest("http://speed.example.com/down", time.Second, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.SetUploadTest("http://speed.example.com/up", 10*time.Second, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.SetNodeBudget(500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// 下载请求立即返回完整响应，上传请求读完请求体后不再应答
+	port := startTCPServer(t, func(conn net.Conn) {
+		rw, err := acceptVLESS(conn, testUUID)
+		if err != nil {
+			return
+		}
+		req, err := http.ReadRequest(bufio.NewReader(rw))
+		if err != nil {
+			return
+		}
+		if req.Method == http.MethodGet {
+			io.WriteString(rw, "HTTP/1.1 200 OK\r\nContent-Length: 65536\r\n\r\n")
+			rw.Write(make([]byte, 65536))
+			return
+		}
+		io.Copy(io.Discard, conn)
+	})
+
+	result := newTestResult(&parser.Node{
+		Name:   "speed-budget",
+		Type:   parser.ProxyTypeVLESS,
+		Server: "127.0.0.1",
+		Port:   port,
+		UUID:   testUUID,
+	})
+	testNodeSpeed(context.Background(), result, testTimeout, opts)
+	if result.DownloadSpeed < 0 || result.DownloadError != nil {
+		t.Errorf("应保留已完成的下载测速: %.1fMbps (%v)", result.DownloadSpeed, result.DownloadError)
+	}
+	if result.UploadSpeed >= 0 || !errors.Is(result.UploadError, errNodeDeadline) {
+		t.Errorf("上传测速应因节点时限跳过: %.1fMbps (%v)", result.UploadSpeed, result.UploadError)
+	}
+}
@@ -0,0 +1,115 @@
+package tester
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LatencyStats 多次采样的延迟统计，单位为毫秒
+// 只统计成功的样本，Success 为 0 时其余字段无意义
+type LatencyStats struct {
+	Sent    int     // 采样次数
+	Success int     // 成功次数
+	Min     int     // 最小值
+	Median  int     // 中位数
+	Avg     float64 // 平均值
+	P95     int     // 95 分位数
+	Max     int     // 最大值
+	Jitter  float64 // 抖动 (标准差)
+}
+
+// newLatencyStats 根据成功样本计算统计值，sent 为采样总次数
+func newLatencyStats(samples []int, sent int) LatencyStats {
+	stats := LatencyStats{Sent: sent, Success: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sorted := append([]int(nil), samples...)
+	sort.Ints(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += float64(v)
+	}
+	stats.Avg = sum / float64(len(sorted))
+
+	var variance float64
+	for _, v := range sorted {
+		d := float64(v) - stats.Avg
+		variance += d * d
+	}
+	stats.Jitter = math.Sqrt(variance / float64(len(sorted)))
+
+	stats.Min = sorted[0]
+	stats.Max = sorted[len(sorted)-1]
+	stats.Median = percentile(sorted, 50)
+	stats.P95 = percentile(sorted, 95)
+	return stats
+}
+
+// percentile 按最近秩法计算已排序样本的分位数；中位数在样本数为偶数时取两个中间值的平均
+func percentile(sorted []int, p int) int {
+	n := len(sorted)
+	if p == 50 && n%2 == 0 {
+		return (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	rank := (p*n + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Loss 返回失败样本的比例 (0-1)
+func (s LatencyStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Success) / float64(s.Sent)
+}
+
+// Value 返回指定的统计值
+func (s LatencyStats) Value(stat Statistic) float64 {
+	switch stat {
+	case StatMin:
+		return float64(s.Min)
+	case StatAvg:
+		return s.Avg
+	case StatP95:
+		return float64(s.P95)
+	case StatMax:
+		return float64(s.Max)
+	case StatJitter:
+		return s.Jitter
+	default:
+		return float64(s.Median)
+	}
+}
+
+// Statistic 延迟统计量，用于排序与显示
+type Statistic string
+
+const (
+	StatMin    Statistic = "min"
+	StatMedian Statistic = "median"
+	StatAvg    Statistic = "avg"
+	StatP95    Statistic = "p95"
+	StatMax    Statistic = "max"
+	StatJitter Statistic = "jitter"
+)
+
+// Statistics 全部可用的统计量
+var Statistics = []Statistic{StatMin, StatMedian, StatAvg, StatP95, StatMax, StatJitter}
+
+// ParseStatistic 解析统计量名称 (不区分大小写)
+func ParseStatistic(name string) (Statistic, error) {
+	for _, stat := range Statistics {
+		if strings.EqualFold(name, string(stat)) {
+			return stat, nil
+		}
+	}
+	return "", fmt.Errorf("未知的统计量: %s (可选 min/median/avg/p95/max/jitter)", name)
+}
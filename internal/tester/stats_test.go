@@ -0,0 +1,89 @@
+package tester
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLatencyStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int
+		sent    int
+		want    LatencyStats
+		loss    float64
+	}{
+		{
+			name: "no-samples",
+			want: LatencyStats{},
+		},
+		{
+			name: "all-failed",
+			sent: 3,
+			want: LatencyStats{Sent: 3},
+			loss: 1,
+		},
+		{
+			name:    "one-sample",
+			samples: []int{42},
+			sent:    1,
+			want:    LatencyStats{Sent: 1, Success: 1, Min: 42, Median: 42, Avg: 42, P95: 42, Max: 42},
+		},
+		{
+			// 偶数个样本的中位数取两个中间值的平均
+			name:    "even-median",
+			samples: []int{40, 10, 30, 20},
+			sent:    5,
+			want:    LatencyStats{Sent: 5, Success: 4, Min: 10, Median: 25, Avg: 25, P95: 40, Max: 40, Jitter: math.Sqrt(125)},
+			loss:    0.2,
+		},
+		{
+			name:    "odd-median",
+			samples: []int{30, 10, 20},
+			sent:    3,
+			want:    LatencyStats{Sent: 3, Success: 3, Min: 10, Median: 20, Avg: 20, P95: 30, Max: 30, Jitter: math.Sqrt(200.0 / 3)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newLatencyStats(tt.samples, tt.sent)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("得到 %+v，期望 %+v", got, tt.want)
+			}
+			if loss := got.Loss(); loss != tt.loss {
+				t.Errorf("丢包率为 %v，期望 %v", loss, tt.loss)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	samples := make([]int, 20)
+	for i := range samples {
+		samples[i] = i + 1
+	}
+	tests := []struct {
+		name   string
+		sorted []int
+		p      int
+		want   int
+	}{
+		{"one-sample", []int{7}, 95, 7},
+		{"p0-first-rank", samples, 0, 1},
+		// 最近秩法: ceil(0.95*20) = 19
+		{"p95-exact-rank", samples, 95, 19},
+		// ceil(0.95*19) = 19，不足一个秩时向上取整
+		{"p95-round-up", samples[:19], 95, 19},
+		{"p100-last", samples, 100, 20},
+		{"median-even-interpolated", []int{1, 2, 4, 6}, 50, 3},
+		{"median-odd", []int{1, 2, 3}, 50, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v, %d) = %d，期望 %d", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package tester
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
 )
 
 // tcpPing 测试TCP连接延迟（绕过系统代理）
-func tcpPing(host, port string, timeout time.Duration) (int, error) {
+func tcpPing(ctx context.Context, host, port string, timeout time.Duration) (int, error) {
 	address := net.JoinHostPort(host, port)
 
 	// 使用直连 dialer 绕过系统代理
@@ -16,7 +17,7 @@ func tcpPing(host, port string, timeout time.Duration) (int, error) {
 	// 在网络操作正前方记录开始时间，确保只测量网络延迟
 	start := time.Now()
 
-	conn, err := dialer.Dial("tcp", address)
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 
 	// 立即计算延迟，避免包含后续操作的时间
 	latency := time.Since(start).Milliseconds()
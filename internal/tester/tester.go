@@ -1,6 +1,8 @@
 package tester
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"proxy-tester/internal/parser"
 	"sync"
@@ -10,8 +12,59 @@ import (
 	"github.com/schollz/progressbar/v3"
 )
 
-// TestNodes 并发测试所有节点
-func TestNodes(nodes []*parser.Node, concurrency int, timeoutSec int) []*TestResult {
+// Options 一次测试运行的设置，由 NewOptions 创建并经各 Set 方法修改后传给 TestNodes 与 TestSpeed
+// 各 Options 互不影响，同一进程中可以同时以不同设置运行多次测试；运行期间不应再修改
+type Options struct {
+	probe      *probeTarget  // 探测目标，由 SetProbe 修改
+	speed      speedConfig   // 测速配置，由 SetSpeedTest 与 SetUploadTest 修改
+	samples    int           // 每个节点的采样次数，由 SetSampling 修改
+	interval   time.Duration // 两次采样之间的间隔
+	nodeBudget time.Duration // 单个节点的总时限，0 表示不限，由 SetNodeBudget 修改
+
+	icmp            bool     // 是否进行 ICMP 测试，由 SetICMP 修改
+	icmpUnavailable [2]error // 启用 ICMP 测试时各地址族无法创建套接字的原因，下标见 icmpFamily
+}
+
+// NewOptions 返回默认设置：请求 DefaultProbeURL，每个节点采样一次，不限节点时限，不进行 ICMP 测试与测速
+func NewOptions() *Options {
+	return &Options{probe: defaultProbe, samples: 1}
+}
+
+// SetSampling 设置每个节点的采样次数与两次采样之间的间隔
+func (o *Options) SetSampling(count int, interval time.Duration) error {
+	if count < 1 {
+		return fmt.Errorf("无效的采样次数: %d", count)
+	}
+	if interval < 0 {
+		return fmt.Errorf("无效的采样间隔: %s", interval)
+	}
+	o.samples = count
+	o.interval = interval
+	return nil
+}
+
+// SetNodeBudget 设置单个节点的总时限，涵盖全部测试阶段与采样，0 表示不限
+func (o *Options) SetNodeBudget(budget time.Duration) error {
+	if budget < 0 {
+		return fmt.Errorf("无效的节点时限: %s", budget)
+	}
+	o.nodeBudget = budget
+	return nil
+}
+
+var (
+	errRunDeadline  error = &failureError{failure: FailureDeadline, err: errors.New("未在总时限内完成测试，已跳过")}
+	errNodeDeadline error = &failureError{failure: FailureDeadline, err: errors.New("未在节点时限内完成测试，已跳过")}
+)
+
+// TestNodes 按 opts 的设置并发测试所有节点，opts 为 nil 时使用 NewOptions 的默认设置
+// ctx 取消后不再派发新的节点并中止进行中的测试：ctx 超出截止时间时未完成的节点标记为跳过，
+// 其他原因取消 (如用户中断) 时只返回已完成测试的节点结果
+func TestNodes(ctx context.Context, nodes []*parser.Node, concurrency int, timeoutSec int, opts *Options) []*TestResult {
+	if opts == nil {
+		opts = NewOptions()
+	}
+
 	// 验证并发参数，防止死锁
 	if concurrency < 1 {
 		concurrency = 1
@@ -30,36 +83,35 @@ func TestNodes(nodes []*parser.Node, concurrency int, timeoutSec int) []*TestRes
 	semaphore := make(chan struct{}, concurrency)
 
 	// 创建美观的进度条
-	bar := progressbar.NewOptions(len(nodes),
-		progressbar.OptionSetDescription(color.CyanString("⚡ 测试节点")),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionShowCount(),
-		progressbar.OptionShowIts(),
-		progressbar.OptionSetItsString("节点/秒"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        color.GreenString("█"),
-			SaucerHead:    color.GreenString("█"),
-			SaucerPadding: color.HiBlackString("░"),
-			BarStart:      color.HiBlackString("│"),
-			BarEnd:        color.HiBlackString("│"),
-		}),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetPredictTime(true),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionClearOnFinish(),
-	)
+	bar := newProgressBar(len(nodes), "⚡ 测试节点")
 
 	// 并发测试
-	for _, node := range nodes {
+dispatch:
+	for i, node := range nodes {
+		// 获取信号量，取消后停止派发
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				resultsMutex.Lock()
+				for _, n := range nodes[i:] {
+					results = append(results, skippedResult(n, errRunDeadline))
+				}
+				resultsMutex.Unlock()
+				bar.Add(len(nodes) - i)
+			}
+			break dispatch
+		}
+
 		wg.Add(1)
 		go func(n *parser.Node) {
 			defer wg.Done()
-			
-			// 获取信号量
-			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 
-			result := testNode(n, timeoutSec)
+			result := testNode(ctx, n, timeoutSec, opts)
+			if result == nil {
+				return
+			}
 			
 			// 保存结果
 			resultsMutex.Lock()
@@ -81,41 +133,175 @@ func TestNodes(nodes []*parser.Node, concurrency int, timeoutSec int) []*TestRes
 	return results
 }
 
+// newProgressBar 创建测试进度条
+func newProgressBar(total int, description string) *progressbar.ProgressBar {
+	return progressbar.NewOptions(total,
+		progressbar.OptionSetDescription(color.CyanString(description)),
+		progressbar.OptionSetWidth(50),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetItsString("节点/秒"),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        color.GreenString("█"),
+			SaucerHead:    color.GreenString("█"),
+			SaucerPadding: color.HiBlackString("░"),
+			BarStart:      color.HiBlackString("│"),
+			BarEnd:        color.HiBlackString("│"),
+		}),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionClearOnFinish(),
+	)
+}
+
+// newTestResult 返回尚无测试数据的节点结果
+func newTestResult(node *parser.Node) *TestResult {
+	return &TestResult{
+		Node:          node,
+		ICMPLatency:   -1,
+		TCPLatency:    -1,
+		ProxyLatency:  -1,
+		DownloadSpeed: -1,
+		UploadSpeed:   -1,
+		HandshakeOnly: isHandshakeOnly(node),
+	}
+}
+
+// skippedResult 返回因超出时限而跳过的节点结果
+func skippedResult(node *parser.Node, err error) *TestResult {
+	result := newTestResult(node)
+	result.Failure = FailureDeadline
+	result.Err = err
+	return result
+}
+
+// deadlineError 返回 ctx 因时限结束时应记录的跳过原因：节点时限先到时为 errNodeDeadline，
+// 到达总时限时为 errRunDeadline；尚未结束或因其他原因 (如用户中断) 取消时返回 nil
+// runCtx 为整个运行的 context，ctx 为由它派生、附加了节点时限的 context
+func deadlineError(runCtx, ctx context.Context) error {
+	switch {
+	case ctx.Err() == nil:
+		return nil
+	case runCtx.Err() == nil:
+		return errNodeDeadline
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		return errRunDeadline
+	}
+	return nil
+}
+
 // testNode 测试单个节点
-func testNode(node *parser.Node, timeoutSec int) *TestResult {
-	result := &TestResult{
-		Node:         node,
-		ICMPLatency:  -1,
-		TCPLatency:   -1,
-		ProxyLatency: -1,
-		Status:       "失败",
+// 每个节点按 opts 的采样设置重复采样，各项延迟取成功样本的中位数；
+// 全部采样受 opts 的节点时限约束。测试中途超出时限时保留已完成的采样，
+// 节点标记为跳过，被时限中止的那次采样不计入统计；被其他原因取消时结果不完整，返回 nil
+func testNode(ctx context.Context, node *parser.Node, timeoutSec int, opts *Options) *TestResult {
+	result := newTestResult(node)
+	start := time.Now()
+
+	runCtx := ctx
+	if opts.nodeBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.nodeBudget)
+		defer cancel()
 	}
 
 	timeout := time.Duration(timeoutSec) * time.Second
 
-	// 1. TCP Ping测试（快速测试端口是否可达）
-	tcpLatency, tcpErr := tcpPing(node.Server, node.Port, timeout)
-	if tcpErr == nil {
-		result.TCPLatency = tcpLatency
+	// completed 判断一次采样是否在取消前完成，被中止的采样不计入发送次数
+	completed := func(err error) bool {
+		return err == nil || ctx.Err() == nil
 	}
 
-	// 2. 真实代理连接测试（包含 TLS 握手等）
-	proxyLatency, proxyErr := testProxyConnection(node, timeout)
-	if proxyErr == nil {
-		result.ProxyLatency = proxyLatency
-		result.Status = "成功"
-	} else {
-		// 记录详细错误信息
-		result.Error = proxyErr.Error()
-		
-		// 如果 TCP 可达但代理测试失败，可能是 TLS 或其他问题
-		// 注意：>= 0 以包含 0ms 的情况（非常快的连接）
-		if result.TCPLatency >= 0 {
-			result.Status = "端口可达但连接失败"
+	var icmpSamples, tcpSamples, proxySamples []int
+	var icmpSent, tcpSent, proxySent int
+	var proxyErr error
+	for i := 0; i < opts.samples; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(opts.interval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		// 0. ICMP Echo测试（可选，判断主机本身是否在线）
+		// 节点地址所属的地址族无法创建 ICMP 套接字时不计入采样
+		if opts.icmp {
+			icmpLatency, icmpErr := opts.icmpPing(ctx, node.Server, timeout)
+			if icmpErr == nil {
+				icmpSamples = append(icmpSamples, icmpLatency)
+			}
+			if !errors.Is(icmpErr, errICMPSkipped) && completed(icmpErr) {
+				icmpSent++
+			}
+		}
+
+		// 1. TCP Ping测试（快速测试端口是否可达）
+		// 基于 QUIC 的节点只监听 UDP 端口，TCP Ping 没有意义
+		if !node.IsUDP() {
+			tcpLatency, tcpErr := tcpPing(ctx, node.Server, node.Port, timeout)
+			if tcpErr == nil {
+				tcpSamples = append(tcpSamples, tcpLatency)
+			}
+			if completed(tcpErr) {
+				tcpSent++
+			}
+		}
+
+		// 2. 真实代理连接测试（包含 TLS 握手等）
+		// 保留最后一次成功采样的分阶段耗时，全部失败时保留最后一次采样的
+		timeline := &Timeline{}
+		proxyLatency, err := testProxyConnection(ctx, node, timeout, opts.probe, timeline)
+		if !completed(err) {
+			break
+		}
+		proxySent++
+		if err == nil {
+			proxySamples = append(proxySamples, proxyLatency)
+			result.Timeline = *timeline
 		} else {
-			result.Status = "超时"
+			proxyErr = err
+			if len(proxySamples) == 0 {
+				result.Timeline = *timeline
+			}
 		}
 	}
+	skipped := deadlineError(runCtx, ctx)
+	if ctx.Err() != nil && skipped == nil {
+		return nil
+	}
+	result.elapsed = time.Since(start)
+
+	if opts.icmp && icmpSent > 0 {
+		result.ICMPStats = newLatencyStats(icmpSamples, icmpSent)
+		if result.ICMPStats.Success > 0 {
+			result.ICMPLatency = result.ICMPStats.Median
+		}
+	}
+	if !node.IsUDP() && tcpSent > 0 {
+		result.TCPStats = newLatencyStats(tcpSamples, tcpSent)
+		if result.TCPStats.Success > 0 {
+			result.TCPLatency = result.TCPStats.Median
+		}
+	}
+	if proxySent > 0 {
+		result.ProxyStats = newLatencyStats(proxySamples, proxySent)
+	}
+
+	if skipped != nil {
+		// 未完成全部采样，保留已完成的统计，但不视为成功，也不参与测速
+		result.Err = skipped
+		result.Failure = FailureDeadline
+	} else if result.ProxyStats.Success > 0 {
+		result.ProxyLatency = result.ProxyStats.Median
+	} else {
+		// 记录错误链并按阶段与原因分类
+		result.Err = proxyErr
+		result.Failure = classifyError(proxyErr)
+	}
 
 	return result
 }
@@ -0,0 +1,92 @@
+package tester
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+	"time"
+)
+
+// 节点时限在真实连接测试中途到达时，保留已完成的 TCP Ping 采样
+func TestNodeBudgetKeepsPartialResult(t *testing.T) {
+	t.Parallel()
+	opts := NewOptions()
+	if err := opts.SetNodeBudget(300 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	// 接受连接但从不应答，真实连接测试一直等待到节点时限
+	port := startTCPServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+
+	result := testNode(context.Background(), &parser.Node{
+		Name:   "budget",
+		Type:   parser.ProxyTypeVLESS,
+		Server: "127.0.0.1",
+		Port:   port,
+		UUID:   testUUID,
+	}, int(testTimeout/time.Second), opts)
+	if result == nil {
+		t.Fatal("超出节点时限的节点不应被丢弃")
+	}
+	if result.Failure != FailureDeadline || !errors.Is(result.Err, errNodeDeadline) {
+		t.Errorf("失败原因为 %s (%v)，期望 %s", result.Failure, result.Err, FailureDeadline)
+	}
+	if result.TCPLatency < 0 || result.TCPStats.Sent != 1 || result.TCPStats.Success != 1 {
+		t.Errorf("应保留 TCP Ping 采样: %dms %+v", result.TCPLatency, result.TCPStats)
+	}
+	// 被时限中止的真实连接测试不计入采样
+	if result.ProxyLatency >= 0 || result.ProxyStats.Sent != 0 {
+		t.Errorf("被中止的真实连接测试不应计入: %dms %+v", result.ProxyLatency, result.ProxyStats)
+	}
+}
+
+// 运行被取消 (如用户中断) 时保留已完成的节点，丢弃进行中与未派发的节点
+func TestNodesCanceledKeepsCompleted(t *testing.T) {
+	t.Parallel()
+	fast := startTCPServer(t, func(conn net.Conn) {
+		serveVLESS(conn, testUUID)
+	})
+	// 收到 VLESS 请求后不再应答，TCP Ping 只建立连接而不发送数据
+	requested := make(chan struct{}, 1)
+	slow := startTCPServer(t, func(conn net.Conn) {
+		if _, err := conn.Read(make([]byte, 1)); err != nil {
+			return
+		}
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		io.Copy(io.Discard, conn)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-requested
+		cancel()
+	}()
+
+	node := func(name string, port string) *parser.Node {
+		return &parser.Node{Name: name, Type: parser.ProxyTypeVLESS, Server: "127.0.0.1", Port: port, UUID: testUUID}
+	}
+	start := time.Now()
+	// 并发数为 1：fast 完成后才派发 slow，slow 进行中时取消，pending 不会被派发
+	results := TestNodes(ctx, []*parser.Node{node("fast", fast), node("slow", slow), node("pending", slow)}, 1, int(testTimeout/time.Second), nil)
+	if elapsed := time.Since(start); elapsed >= testTimeout {
+		t.Errorf("取消后应立即返回，耗时 %s", elapsed)
+	}
+	if len(results) != 1 || results[0].Node.Name != "fast" {
+		names := make([]string, len(results))
+		for i, r := range results {
+			names[i] = r.Node.Name
+		}
+		t.Fatalf("结果为 %v，期望只保留 fast", names)
+	}
+	if !results[0].IsSuccess() {
+		t.Errorf("已完成的节点应保留测试结果: %v", results[0].Err)
+	}
+}
@@ -0,0 +1,66 @@
+package tester
+
+import (
+	"time"
+)
+
+// TimedStages 记录耗时的阶段，按发生顺序排列
+var TimedStages = []Stage{
+	StageDNS,
+	StageConnect,
+	StageTLS,
+	StageTransport,
+	StageHandshake,
+	StageProbe,
+}
+
+// Timeline 一次真实连接测试的分阶段耗时
+// 每个阶段的耗时为上一阶段结束到该阶段结束的时间，未经历的阶段不记录；
+// 方法对 nil 接收者安全，无需记录时传入 nil 即可
+type Timeline struct {
+	durations map[Stage]time.Duration
+	last      time.Time
+}
+
+// start 开始计时
+func (t *Timeline) start() {
+	if t == nil {
+		return
+	}
+	t.durations = make(map[Stage]time.Duration)
+	t.last = time.Now()
+}
+
+// mark 记录 stage 阶段在此刻结束
+func (t *Timeline) mark(stage Stage) {
+	t.markAt(stage, time.Now())
+}
+
+// markAt 记录 stage 阶段在 at 时刻结束
+func (t *Timeline) markAt(stage Stage, at time.Time) {
+	if t == nil || t.durations == nil {
+		return
+	}
+	t.durations[stage] += at.Sub(t.last)
+	t.last = at
+}
+
+// Duration 返回阶段耗时，ok 表示该阶段是否已完成
+func (t Timeline) Duration(stage Stage) (d time.Duration, ok bool) {
+	d, ok = t.durations[stage]
+	return d, ok
+}
+
+// Total 返回已完成阶段的耗时之和
+func (t Timeline) Total() time.Duration {
+	var total time.Duration
+	for _, d := range t.durations {
+		total += d
+	}
+	return total
+}
+
+// Recorded 判断是否记录了分阶段耗时
+func (t Timeline) Recorded() bool {
+	return len(t.durations) > 0
+}
@@ -0,0 +1,75 @@
+package tester
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"proxy-tester/internal/parser"
+	"time"
+)
+
+// transport 传输层实现：建立到节点的连接，返回可直接承载代理协议数据的流
+type transport interface {
+	dial(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error)
+}
+
+// transports 按 Node.Network 注册的传输层实现
+var transports = map[string]transport{
+	"":            tcpTransport{},
+	"tcp":         tcpTransport{},
+	"ws":          streamTransport{upgrade: dialWebSocketTransport},
+	"grpc":        streamTransport{alpn: []string{"h2"}, upgrade: dialGRPC},
+	"h2":          streamTransport{alpn: []string{"h2"}, upgrade: dialH2},
+	"http":        streamTransport{alpn: []string{"h2"}, upgrade: dialH2},
+	"httpupgrade": streamTransport{alpn: []string{"http/1.1"}, upgrade: dialHTTPUpgrade},
+	"xhttp":       streamTransport{alpn: []string{"h2"}, upgrade: dialXHTTP},
+	"splithttp":   streamTransport{alpn: []string{"h2"}, upgrade: dialXHTTP},
+	"kcp":         kcpTransport{},
+	"mkcp":        kcpTransport{},
+}
+
+// dialTransport 按节点声明的传输方式建立连接，各阶段耗时记录到 timeline
+func dialTransport(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	t, ok := transports[node.Network]
+	if !ok {
+		return nil, unsupported(fmt.Errorf("暂不支持的传输方式: %s", node.Network))
+	}
+	return t.dial(ctx, node, timeout, timeline)
+}
+
+// streamTransport 基于 TCP (可叠加 TLS/REALITY) 的传输层
+// 连接建立后由 upgrade 在其上完成传输层握手，upgrade 为空时直接使用该连接
+type streamTransport struct {
+	alpn    []string // 节点未声明 ALPN 时 TLS 握手协商的协议
+	upgrade func(conn net.Conn, node *parser.Node) (net.Conn, error)
+}
+
+func (t streamTransport) dial(ctx context.Context, node *parser.Node, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	if len(node.Security.ALPN) == 0 && len(t.alpn) > 0 {
+		withALPN := *node
+		withALPN.Security.ALPN = t.alpn
+		node = &withALPN
+	}
+
+	conn, err := dialNode(ctx, node, timeout, timeline)
+	if err != nil || t.upgrade == nil {
+		return conn, err
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	stream, err := t.upgrade(conn, node)
+	if err != nil {
+		conn.Close()
+		return nil, atStage(StageTransport, err)
+	}
+	if deferred, ok := stream.(deferredTransport); !ok || !deferred.deferHandshake(timeline) {
+		timeline.mark(StageTransport)
+	}
+	return stream, nil
+}
+
+// deferredTransport 由可将握手推迟到首次写入的传输层连接实现 (如 WebSocket 早期数据)
+// 握手确实被推迟时 deferHandshake 返回 true，连接在握手完成时自行记录传输层阶段
+type deferredTransport interface {
+	deferHandshake(timeline *Timeline) bool
+}
@@ -0,0 +1,69 @@
+package tester
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"proxy-tester/internal/parser"
+	"time"
+)
+
+// trojanCmdConnect Trojan 协议中的 CONNECT 命令
+const trojanCmdConnect = 0x01
+
+// testTrojanConnection 测试Trojan连接
+// 完成 TLS 握手后发送 Trojan 请求头并经隧道请求探测地址，
+// 密码错误时服务器会将流量转交给回落站点，探测请求因此无法得到预期响应
+func testTrojanConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
+	start := time.Now()
+
+	conn, err := dialTrojanTunnel(ctx, node, target.host, target.port, timeout, timeline)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	// Trojan 请求头与首个数据包一起发送
+	firstByte, err := probeTunnel(conn, target, timeline)
+	if err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("Trojan握手失败(密码错误或节点不可用): %w", err))
+	}
+
+	return int(firstByte.Sub(start).Milliseconds()), nil
+}
+
+// dialTrojanTunnel 建立到 host:port 的 Trojan 隧道
+// 使用节点声明的 SNI 完成 TLS 握手并按节点的传输方式建立连接，请求头在首次写入时发送
+func dialTrojanTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	header := buildTrojanRequest(node.Password, host, port)
+
+	conn, err := dialTransport(ctx, node, timeout, timeline)
+	if err != nil {
+		return nil, fmt.Errorf("Trojan连接失败: %w", err)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	return &trojanConn{prefixConn{Conn: conn, prefix: header}}, nil
+}
+
+// trojanConn Trojan 隧道，服务器不返回任何应答头，见 unverifiedTunnel
+type trojanConn struct {
+	prefixConn
+}
+
+func (*trojanConn) unverifiedResponse() {}
+
+// buildTrojanRequest 构造 Trojan 请求头
+// 格式: hex(SHA224(password)) CRLF CMD ATYP DST.ADDR DST.PORT CRLF
+func buildTrojanRequest(password string, host string, port int) []byte {
+	hash := sha256.Sum224([]byte(password))
+
+	buf := make([]byte, 0, 56+2+1+len(host)+8+2)
+	buf = append(buf, hex.EncodeToString(hash[:])...)
+	buf = append(buf, '\r', '\n', trojanCmdConnect)
+	buf = append(buf, encodeSocksAddr(host, port)...)
+	buf = append(buf, '\r', '\n')
+	return buf
+}
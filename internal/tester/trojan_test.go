@@ -0,0 +1,70 @@
+package tester
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"testing"
+)
+
+// startTrojanServer 启动 Trojan 测试服务器
+// 密码错误时与配置了回落的服务器一样，由回落站点应答无法解析的请求
+func startTrojanServer(t *testing.T, password string) string {
+	config := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, "example.com")}}
+	hash := sha256.Sum224([]byte(password))
+
+	return startTCPServer(t, func(conn net.Conn) {
+		tlsConn := tls.Server(conn, config)
+		reader := bufio.NewReader(tlsConn)
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if line != hex.EncodeToString(hash[:])+"\r\n" {
+			io.WriteString(tlsConn, "HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+			return
+		}
+		// CMD ATYP DST.ADDR DST.PORT CRLF
+		if _, err := reader.ReadByte(); err != nil {
+			return
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			return
+		}
+		serveProbe(struct {
+			io.Reader
+			io.Writer
+		}{reader, tlsConn})
+	})
+}
+
+func TestTrojanFallback(t *testing.T) {
+	port := startTrojanServer(t, "secret")
+
+	tests := []struct {
+		name     string
+		password string
+		want     Failure
+	}{
+		{"ok", "secret", FailureNone},
+		// 回落站点的 400 应答不能归咎于探测目标
+		{"wrong-password", "wrong", FailureAuthRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectFailure(t, &parser.Node{
+				Name:     tt.name,
+				Type:     parser.ProxyTypeTrojan,
+				Server:   "127.0.0.1",
+				Port:     port,
+				Password: tt.password,
+				TLS:      true,
+				Security: parser.Security{Type: "tls", SNI: "example.com", AllowInsecure: true},
+			}, tt.want)
+		})
+	}
+}
@@ -2,19 +2,52 @@ package tester
 
 import (
 	"proxy-tester/internal/parser"
+	"time"
 )
 
 // TestResult 测试结果
 type TestResult struct {
-	Node         *parser.Node
-	ICMPLatency  int    // ICMP延迟(ms), -1表示失败
-	TCPLatency   int    // TCP延迟(ms), -1表示失败
-	ProxyLatency int    // 真实代理连接延迟(ms), -1表示失败
-	Status       string // 状态: 成功/超时/失败
-	Error        string // 错误信息
+	Node          *parser.Node
+	ICMPLatency   int          // ICMP延迟(ms), -1表示失败
+	TCPLatency    int          // TCP延迟(ms), -1表示失败
+	ProxyLatency  int          // 真实代理连接延迟(ms), -1表示失败
+	ICMPStats     LatencyStats // ICMP延迟的多次采样统计，未启用时 Sent 为 0
+	TCPStats      LatencyStats // TCP延迟的多次采样统计
+	ProxyStats    LatencyStats // 真实代理连接延迟的多次采样统计
+	Timeline      Timeline     // 真实连接测试的分阶段耗时
+	DownloadSpeed float64      // 下载速度(Mbps), -1表示未测速或测速失败
+	UploadSpeed   float64      // 上传速度(Mbps), -1表示未测速或测速失败
+	Failure       Failure      // 失败原因，成功时为 FailureNone
+	HandshakeOnly bool         // 真实延迟仅为与节点的握手耗时 (Hysteria2/TUIC)，未经节点请求探测地址
+	Err           error        // 真实连接测试的错误，保留完整的错误链
+	DownloadError error        // 下载测速的错误，按 Failure 分类见 DownloadFailure
+	UploadError   error        // 上传测速的错误，按 Failure 分类见 UploadFailure
+
+	elapsed time.Duration // 连接测试已用时间，测速阶段据此扣减节点时限
 }
 
 // IsSuccess 判断测试是否成功
+// 以代理协议测试结果为准，仅端口可达（如密码错误）不视为成功
 func (r *TestResult) IsSuccess() bool {
-	return r.ProxyLatency > 0 || r.TCPLatency > 0
+	return r.ProxyLatency >= 0
+}
+
+// DownloadTested 判断节点是否进行过下载测速
+func (r *TestResult) DownloadTested() bool {
+	return r.DownloadSpeed >= 0 || r.DownloadError != nil
+}
+
+// UploadTested 判断节点是否进行过上传测速
+func (r *TestResult) UploadTested() bool {
+	return r.UploadSpeed >= 0 || r.UploadError != nil
+}
+
+// DownloadFailure 返回下载测速的失败原因，未测速或成功时为 FailureNone
+func (r *TestResult) DownloadFailure() Failure {
+	return classifyError(r.DownloadError)
+}
+
+// UploadFailure 返回上传测速的失败原因，未测速或成功时为 FailureNone
+func (r *TestResult) UploadFailure() Failure {
+	return classifyError(r.UploadError)
 }
@@ -0,0 +1,267 @@
+package tester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"time"
+)
+
+// VLESS 请求头中的常量
+const (
+	vlessVersion = 0x00
+	vlessCmdTCP  = 0x01
+
+	// 地址类型（与 VMess 相同）
+	vlessAddrIPv4   = 0x01
+	vlessAddrDomain = 0x02
+	vlessAddrIPv6   = 0x03
+)
+
+// XTLS Vision 流控
+const (
+	vlessFlowVision = "xtls-rprx-vision"
+
+	// Vision 填充块命令
+	visionCmdPaddingContinue = 0x00
+	visionCmdPaddingEnd      = 0x01
+	visionCmdPaddingDirect   = 0x02
+)
+
+// testVLESSConnection 测试VLESS连接
+// 在节点声明的传输层上发送 VLESS 请求头并经隧道请求探测地址，
+// UUID 过期或错误时服务器不会返回有效响应，探测请求因此失败
+func testVLESSConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
+	start := time.Now()
+
+	conn, err := dialVLESSTunnel(ctx, node, target.host, target.port, timeout, timeline)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	// 请求头与首个数据包一起发送
+	firstByte, err := probeTunnel(conn, target, timeline)
+	if err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("VLESS握手失败(UUID错误或节点不可用): %w", err))
+	}
+
+	return int(firstByte.Sub(start).Milliseconds()), nil
+}
+
+// dialVLESSTunnel 在节点声明的传输层上建立到 host:port 的 VLESS 隧道
+// 请求头在首次写入时发送，连接的读写超时为 timeout
+func dialVLESSTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	conn, err := newVLESSConn(node, host, port)
+	if err != nil {
+		return nil, atStage(StageConfig, fmt.Errorf("VLESS配置错误: %w", err))
+	}
+
+	raw, err := dialTransport(ctx, node, timeout, timeline)
+	if err != nil {
+		return nil, fmt.Errorf("VLESS连接失败: %w", err)
+	}
+
+	raw.SetDeadline(time.Now().Add(timeout))
+	conn.Conn = raw
+	conn.reader = bufio.NewReader(raw)
+	return conn, nil
+}
+
+// vlessConn 在底层连接上实现 VLESS 客户端
+// 首次写入时发送请求头，首次读取时解析响应头；启用 Vision 流控时负责填充与去填充
+type vlessConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	id     [16]byte
+	vision bool
+	host   string
+	port   int
+
+	headerSent bool
+	headerRead bool
+
+	// Vision 去填充状态
+	unpadChecked     bool
+	unpadDone        bool
+	remainingContent int
+	remainingPadding int
+	lastCommand      byte
+}
+
+// newVLESSConn 根据节点的 UUID 与流控构造 VLESS 客户端，底层连接稍后赋值
+func newVLESSConn(node *parser.Node, host string, port int) (*vlessConn, error) {
+	id, err := parseUUID(node.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &vlessConn{id: id, host: host, port: port}
+	switch node.Flow {
+	case "":
+	case vlessFlowVision, vlessFlowVision + "-udp443":
+		// Vision 依赖外层 TLS 承载，服务端会拒绝明文连接
+		if !node.TLS {
+			return nil, errors.New("xtls-rprx-vision 流控需要 TLS 或 REALITY")
+		}
+		c.vision = true
+	default:
+		return nil, unsupported(fmt.Errorf("不支持的VLESS流控: %s", node.Flow))
+	}
+	return c, nil
+}
+
+// buildRequestHeader 构造 VLESS 请求头
+// 格式: Ver UUID(16) AddonsLen Addons Cmd Port Atyp Addr
+func (c *vlessConn) buildRequestHeader() []byte {
+	var addons []byte
+	if c.vision {
+		// Addons 为 protobuf 编码，仅包含字段 1 (Flow)
+		addons = append(addons, 0x0a, byte(len(vlessFlowVision)))
+		addons = append(addons, vlessFlowVision...)
+	}
+
+	buf := make([]byte, 0, 1+16+1+len(addons)+1+2+1+len(c.host)+16)
+	buf = append(buf, vlessVersion)
+	buf = append(buf, c.id[:]...)
+	buf = append(buf, byte(len(addons)))
+	buf = append(buf, addons...)
+	buf = append(buf, vlessCmdTCP)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(c.port))
+
+	if ip := net.ParseIP(c.host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, vlessAddrIPv4)
+			buf = append(buf, ip4...)
+		} else {
+			buf = append(buf, vlessAddrIPv6)
+			buf = append(buf, ip.To16()...)
+		}
+	} else {
+		buf = append(buf, vlessAddrDomain, byte(len(c.host)))
+		buf = append(buf, c.host...)
+	}
+	return buf
+}
+
+// Write 首次写入时附带请求头；启用 Vision 时首个数据包以填充块发送并结束填充
+// 探测请求为明文 HTTP，不存在可直接拷贝的内层 TLS 流量，因此一个填充块即可
+func (c *vlessConn) Write(p []byte) (int, error) {
+	var buf []byte
+	if !c.headerSent {
+		buf = c.buildRequestHeader()
+		if c.vision {
+			buf = append(buf, c.id[:]...)
+			buf = appendVisionPadding(buf, visionCmdPaddingEnd, p)
+		} else {
+			buf = append(buf, p...)
+		}
+		c.headerSent = true
+	} else {
+		buf = p
+	}
+
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// appendVisionPadding 追加一个 Vision 填充块
+// 格式: Cmd ContentLen(2) PaddingLen(2) Content Padding
+func appendVisionPadding(dst []byte, command byte, content []byte) []byte {
+	var n [2]byte
+	rand.Read(n[:])
+	padding := int(binary.BigEndian.Uint16(n[:]) % 256)
+	// 与 Xray 一致，短数据包填充至 900 字节以上以隐藏长度特征
+	if len(content) < 900 {
+		padding += 900 - len(content)
+	}
+
+	dst = append(dst, command)
+	dst = binary.BigEndian.AppendUint16(dst, uint16(len(content)))
+	dst = binary.BigEndian.AppendUint16(dst, uint16(padding))
+	dst = append(dst, content...)
+	return append(dst, make([]byte, padding)...)
+}
+
+// Read 首次读取时解析响应头，启用 Vision 时去除服务器返回数据中的填充
+func (c *vlessConn) Read(p []byte) (int, error) {
+	if !c.headerRead {
+		if err := c.readResponseHeader(); err != nil {
+			return 0, atStage(StageHandshake, err)
+		}
+		c.headerRead = true
+	}
+
+	if !c.vision || c.unpadDone {
+		return c.reader.Read(p)
+	}
+	return c.readUnpadded(p)
+}
+
+// readResponseHeader 读取响应头: Ver AddonsLen Addons
+func (c *vlessConn) readResponseHeader() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return fmt.Errorf("读取VLESS响应头失败: %w", err)
+	}
+	if header[0] != vlessVersion {
+		return fmt.Errorf("VLESS响应版本错误: %d", header[0])
+	}
+	if _, err := c.reader.Discard(int(header[1])); err != nil {
+		return fmt.Errorf("读取VLESS响应头失败: %w", err)
+	}
+	return nil
+}
+
+// readUnpadded 按 Vision 格式解析服务器返回的数据
+// 首个填充块前带有用户 UUID；收到结束或直连命令后，后续数据不再填充
+func (c *vlessConn) readUnpadded(p []byte) (int, error) {
+	if !c.unpadChecked {
+		c.unpadChecked = true
+		prefix, err := c.reader.Peek(16)
+		if err != nil || !bytes.Equal(prefix, c.id[:]) {
+			// 服务器未对响应填充，按原始数据读取
+			c.unpadDone = true
+			return c.reader.Read(p)
+		}
+		c.reader.Discard(16)
+	}
+
+	for c.remainingContent == 0 {
+		if c.remainingPadding > 0 {
+			if _, err := c.reader.Discard(c.remainingPadding); err != nil {
+				return 0, err
+			}
+			c.remainingPadding = 0
+		}
+		if c.lastCommand != visionCmdPaddingContinue {
+			c.unpadDone = true
+			return c.reader.Read(p)
+		}
+
+		var header [5]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return 0, err
+		}
+		c.lastCommand = header[0]
+		c.remainingContent = int(binary.BigEndian.Uint16(header[1:3]))
+		c.remainingPadding = int(binary.BigEndian.Uint16(header[3:5]))
+	}
+
+	if len(p) > c.remainingContent {
+		p = p[:c.remainingContent]
+	}
+	n, err := c.reader.Read(p)
+	c.remainingContent -= n
+	return n, err
+}
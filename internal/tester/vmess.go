@@ -0,0 +1,517 @@
+package tester
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"proxy-tester/internal/parser"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/sha3"
+)
+
+// VMess 请求头中的常量
+const (
+	vmessVersion = 0x01
+	vmessCmdTCP  = 0x01
+
+	// 选项位：分块传输与长度混淆
+	vmessOptChunkStream  = 0x01
+	vmessOptChunkMasking = 0x04
+
+	// 数据加密方式
+	vmessSecurityAES128GCM = 0x03
+	vmessSecurityChacha20  = 0x04
+	vmessSecurityNone      = 0x05
+
+	// 地址类型（与 SOCKS5 编号不同）
+	vmessAddrIPv4   = 0x01
+	vmessAddrDomain = 0x02
+	vmessAddrIPv6   = 0x03
+
+	// 单个数据块的最大明文长度
+	vmessChunkSize = 8192
+)
+
+// vmessCmdKeySalt 由 UUID 派生指令密钥时使用的固定盐值
+const vmessCmdKeySalt = "c48619fe-8f02-49e0-b9e9-edf763e17e21"
+
+// VMess AEAD 各阶段 KDF 使用的路径
+const (
+	vmessKDFSalt                = "VMess AEAD KDF"
+	vmessKDFAuthID              = "AES Auth ID Encryption"
+	vmessKDFHeaderKey           = "VMess Header AEAD Key"
+	vmessKDFHeaderIV            = "VMess Header AEAD Nonce"
+	vmessKDFHeaderLengthKey     = "VMess Header AEAD Key_Length"
+	vmessKDFHeaderLengthIV      = "VMess Header AEAD Nonce_Length"
+	vmessKDFRespHeaderKey       = "AEAD Resp Header Key"
+	vmessKDFRespHeaderIV        = "AEAD Resp Header IV"
+	vmessKDFRespHeaderLengthKey = "AEAD Resp Header Len Key"
+	vmessKDFRespHeaderLengthIV  = "AEAD Resp Header Len IV"
+)
+
+// testVMessConnection 测试VMess连接
+// 在节点声明的传输层上完成 VMess AEAD 握手并经隧道请求探测地址，
+// UUID 错误时服务器无法解密认证头，探测请求因此无法得到预期响应
+func testVMessConnection(ctx context.Context, node *parser.Node, timeout time.Duration, target *probeTarget, timeline *Timeline) (int, error) {
+	start := time.Now()
+
+	conn, err := dialVMessTunnel(ctx, node, target.host, target.port, timeout, timeline)
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	// 请求头与首个数据块一起发送
+	firstByte, err := probeTunnel(conn, target, timeline)
+	if err != nil {
+		return -1, atStage(StageHandshake, fmt.Errorf("VMess握手失败(UUID错误或节点不可用): %w", err))
+	}
+
+	return int(firstByte.Sub(start).Milliseconds()), nil
+}
+
+// dialVMessTunnel 在节点声明的传输层上建立到 host:port 的 VMess 隧道
+// 认证头与请求头在首次写入时发送，连接的读写超时为 timeout
+func dialVMessTunnel(ctx context.Context, node *parser.Node, host string, port int, timeout time.Duration, timeline *Timeline) (net.Conn, error) {
+	conn, err := newVMessConn(node, host, port)
+	if err != nil {
+		return nil, atStage(StageConfig, fmt.Errorf("VMess配置错误: %w", err))
+	}
+
+	raw, err := dialTransport(ctx, node, timeout, timeline)
+	if err != nil {
+		return nil, fmt.Errorf("VMess连接失败: %w", err)
+	}
+
+	raw.SetDeadline(time.Now().Add(timeout))
+	conn.Conn = raw
+	return conn, nil
+}
+
+// vmessConn 在底层连接上实现 VMess AEAD 客户端
+// 首次写入时发送认证头与请求头，首次读取时校验响应头
+type vmessConn struct {
+	net.Conn
+
+	cmdKey   [16]byte
+	security byte
+	option   byte
+	host     string
+	port     int
+
+	reqKey, reqIV   [16]byte
+	respKey, respIV [16]byte
+	respV           byte
+
+	writer *vmessChunkStream
+	reader *vmessChunkStream
+
+	headerSent bool
+	headerRead bool
+	pending    []byte
+	eof        bool
+}
+
+// newVMessConn 根据节点的 UUID 与加密方式构造 VMess 客户端，底层连接稍后赋值
+func newVMessConn(node *parser.Node, host string, port int) (*vmessConn, error) {
+	id, err := parseUUID(node.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	security, err := vmessSecurity(node.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &vmessConn{
+		security: security,
+		host:     host,
+		port:     port,
+	}
+	c.cmdKey = md5.Sum(append(id[:], vmessCmdKeySalt...))
+
+	if _, err := rand.Read(c.reqKey[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(c.reqIV[:]); err != nil {
+		return nil, err
+	}
+	respKey := sha256.Sum256(c.reqKey[:])
+	respIV := sha256.Sum256(c.reqIV[:])
+	copy(c.respKey[:], respKey[:16])
+	copy(c.respIV[:], respIV[:16])
+	c.respV = byte(mathrand.Intn(256))
+
+	// zero 方式以 none 声明且不分块，直接传输明文
+	if !strings.EqualFold(node.Method, "zero") {
+		c.option = vmessOptChunkStream | vmessOptChunkMasking
+	}
+
+	if c.writer, err = newVMessChunkStream(security, c.option, c.reqKey, c.reqIV); err != nil {
+		return nil, err
+	}
+	if c.reader, err = newVMessChunkStream(security, c.option, c.respKey, c.respIV); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// vmessSecurity 将订阅中的加密方式映射为协议中的编号，auto 与空值按 aes-128-gcm 处理
+func vmessSecurity(method string) (byte, error) {
+	switch strings.ToLower(method) {
+	case "", "auto", "aes-128-gcm":
+		return vmessSecurityAES128GCM, nil
+	case "chacha20-poly1305", "chacha20-ietf-poly1305":
+		return vmessSecurityChacha20, nil
+	case "none", "zero":
+		return vmessSecurityNone, nil
+	default:
+		return 0, unsupported(fmt.Errorf("不支持的VMess加密方式: %s", method))
+	}
+}
+
+// Write 首次写入时附带 AEAD 认证头与请求头
+func (c *vmessConn) Write(p []byte) (int, error) {
+	var buf []byte
+	if !c.headerSent {
+		header, err := c.sealHeader()
+		if err != nil {
+			return 0, err
+		}
+		buf = header
+		c.headerSent = true
+	}
+
+	for rest := p; len(rest) > 0; {
+		n := len(rest)
+		if n > vmessChunkSize {
+			n = vmessChunkSize
+		}
+		buf = c.writer.seal(buf, rest[:n])
+		rest = rest[n:]
+	}
+
+	if _, err := c.Conn.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Read 首次读取时解密并校验响应头，之后逐块解密数据
+func (c *vmessConn) Read(p []byte) (int, error) {
+	if !c.headerRead {
+		if err := c.readResponseHeader(); err != nil {
+			return 0, atStage(StageHandshake, err)
+		}
+		c.headerRead = true
+	}
+
+	for len(c.pending) == 0 {
+		if c.eof {
+			return 0, io.EOF
+		}
+		chunk, err := c.reader.open(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		// 空数据块表示服务器结束传输
+		if chunk == nil {
+			c.eof = true
+			continue
+		}
+		c.pending = chunk
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// sealHeader 构造请求头并使用 AEAD 方式加密
+// 格式: AuthID(16) + 加密的长度(2+16) + 随机数(8) + 加密的请求头
+func (c *vmessConn) sealHeader() ([]byte, error) {
+	header := c.buildRequestHeader()
+
+	authID, err := vmessAuthID(c.cmdKey[:], time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, 16+18+8+len(header)+16)
+	out = append(out, authID[:]...)
+
+	length := binary.BigEndian.AppendUint16(nil, uint16(len(header)))
+	lengthAEAD := mustAESGCM(vmessKDF(c.cmdKey[:], vmessKDFHeaderLengthKey, string(authID[:]), string(nonce))[:16])
+	out = lengthAEAD.Seal(out, vmessKDF(c.cmdKey[:], vmessKDFHeaderLengthIV, string(authID[:]), string(nonce))[:12], length, authID[:])
+
+	out = append(out, nonce...)
+
+	headerAEAD := mustAESGCM(vmessKDF(c.cmdKey[:], vmessKDFHeaderKey, string(authID[:]), string(nonce))[:16])
+	out = headerAEAD.Seal(out, vmessKDF(c.cmdKey[:], vmessKDFHeaderIV, string(authID[:]), string(nonce))[:12], header, authID[:])
+
+	return out, nil
+}
+
+// buildRequestHeader 构造明文请求头
+// 格式: Ver IV(16) Key(16) V Opt P|Sec Rsv Cmd Port Atyp Addr Padding F(FNV1a)
+func (c *vmessConn) buildRequestHeader() []byte {
+	padding := mathrand.Intn(16)
+
+	buf := make([]byte, 0, 1+16+16+4+1+2+1+len(c.host)+16+padding+4)
+	buf = append(buf, vmessVersion)
+	buf = append(buf, c.reqIV[:]...)
+	buf = append(buf, c.reqKey[:]...)
+	buf = append(buf, c.respV, c.option, byte(padding<<4)|c.security, 0x00, vmessCmdTCP)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(c.port))
+
+	if ip := net.ParseIP(c.host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			buf = append(buf, vmessAddrIPv4)
+			buf = append(buf, ip4...)
+		} else {
+			buf = append(buf, vmessAddrIPv6)
+			buf = append(buf, ip.To16()...)
+		}
+	} else {
+		buf = append(buf, vmessAddrDomain, byte(len(c.host)))
+		buf = append(buf, c.host...)
+	}
+
+	pad := make([]byte, padding)
+	rand.Read(pad)
+	buf = append(buf, pad...)
+
+	checksum := fnv.New32a()
+	checksum.Write(buf)
+	return checksum.Sum(buf)
+}
+
+// readResponseHeader 解密响应头并校验服务器回显的响应认证字节
+func (c *vmessConn) readResponseHeader() error {
+	lengthAEAD := mustAESGCM(vmessKDF(c.respKey[:], vmessKDFRespHeaderLengthKey)[:16])
+	lengthBuf := make([]byte, 2+lengthAEAD.Overhead())
+	if _, err := io.ReadFull(c.Conn, lengthBuf); err != nil {
+		return fmt.Errorf("读取VMess响应头失败: %w", err)
+	}
+	length, err := lengthAEAD.Open(nil, vmessKDF(c.respIV[:], vmessKDFRespHeaderLengthIV)[:12], lengthBuf, nil)
+	if err != nil {
+		return errors.New("VMess响应头解密失败")
+	}
+
+	headerAEAD := mustAESGCM(vmessKDF(c.respKey[:], vmessKDFRespHeaderKey)[:16])
+	headerBuf := make([]byte, int(binary.BigEndian.Uint16(length))+headerAEAD.Overhead())
+	if _, err := io.ReadFull(c.Conn, headerBuf); err != nil {
+		return fmt.Errorf("读取VMess响应头失败: %w", err)
+	}
+	header, err := headerAEAD.Open(nil, vmessKDF(c.respIV[:], vmessKDFRespHeaderIV)[:12], headerBuf, nil)
+	if err != nil {
+		return errors.New("VMess响应头解密失败")
+	}
+
+	if len(header) < 4 || header[0] != c.respV {
+		return errors.New("VMess响应头校验失败")
+	}
+	return nil
+}
+
+// vmessAuthID 生成 AEAD 认证 ID
+// 明文为 时间戳(8) + 随机数(4) + CRC32(4)，使用由指令密钥派生的 AES 密钥加密
+func vmessAuthID(cmdKey []byte, timestamp int64) ([16]byte, error) {
+	var authID [16]byte
+	binary.BigEndian.PutUint64(authID[:8], uint64(timestamp))
+	if _, err := rand.Read(authID[8:12]); err != nil {
+		return authID, err
+	}
+	binary.BigEndian.PutUint32(authID[12:], crc32.ChecksumIEEE(authID[:12]))
+
+	block, err := aes.NewCipher(vmessKDF(cmdKey, vmessKDFAuthID)[:16])
+	if err != nil {
+		return authID, err
+	}
+	block.Encrypt(authID[:], authID[:])
+	return authID, nil
+}
+
+// vmessKDF VMess AEAD 使用的密钥派生函数：以盐值为根逐层嵌套 HMAC-SHA256
+func vmessKDF(key []byte, path ...string) []byte {
+	newHash := func() hash.Hash { return hmac.New(sha256.New, []byte(vmessKDFSalt)) }
+	for _, p := range path {
+		parent, value := newHash, []byte(p)
+		newHash = func() hash.Hash { return hmac.New(parent, value) }
+	}
+
+	h := newHash()
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// mustAESGCM 创建 AES-GCM AEAD，密钥均由 KDF 截取为固定长度，构造失败属于编程错误
+func mustAESGCM(key []byte) cipher.AEAD {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// vmessChunkStream 单方向的 VMess 数据分块编解码
+// 每块格式: 混淆后的长度(2) + 加密数据，nonce 为 计数器(2) + IV[2:12]
+type vmessChunkStream struct {
+	aead  cipher.AEAD
+	iv    [16]byte
+	count uint16
+	mask  sha3.ShakeHash
+	chunk bool
+}
+
+// newVMessChunkStream 按加密方式与选项创建分块编解码器
+func newVMessChunkStream(security byte, option byte, key [16]byte, iv [16]byte) (*vmessChunkStream, error) {
+	s := &vmessChunkStream{iv: iv, chunk: option&vmessOptChunkStream != 0}
+
+	switch security {
+	case vmessSecurityAES128GCM:
+		s.aead = mustAESGCM(key[:])
+	case vmessSecurityChacha20:
+		// ChaCha20-Poly1305 的 32 字节密钥由 MD5(key) + MD5(MD5(key)) 组成
+		first := md5.Sum(key[:])
+		second := md5.Sum(first[:])
+		aead, err := chacha20poly1305.New(append(first[:], second[:]...))
+		if err != nil {
+			return nil, err
+		}
+		s.aead = aead
+	}
+
+	if option&vmessOptChunkMasking != 0 {
+		s.mask = sha3.NewShake128()
+		s.mask.Write(iv[:])
+	}
+	return s, nil
+}
+
+// overhead 返回每块数据附加的认证标签长度
+func (s *vmessChunkStream) overhead() int {
+	if s.aead == nil {
+		return 0
+	}
+	return s.aead.Overhead()
+}
+
+// nextMask 返回下一个长度混淆值，未启用混淆时为 0
+func (s *vmessChunkStream) nextMask() uint16 {
+	if s.mask == nil {
+		return 0
+	}
+	var b [2]byte
+	s.mask.Read(b[:])
+	return binary.BigEndian.Uint16(b[:])
+}
+
+// nonce 返回当前数据块使用的 nonce 并递增计数器
+func (s *vmessChunkStream) nonce() []byte {
+	nonce := make([]byte, s.aead.NonceSize())
+	binary.BigEndian.PutUint16(nonce, s.count)
+	copy(nonce[2:], s.iv[2:12])
+	s.count++
+	return nonce
+}
+
+// seal 将一块明文编码后追加到 dst
+func (s *vmessChunkStream) seal(dst []byte, payload []byte) []byte {
+	if !s.chunk {
+		return append(dst, payload...)
+	}
+
+	size := uint16(len(payload) + s.overhead())
+	dst = binary.BigEndian.AppendUint16(dst, size^s.nextMask())
+	if s.aead == nil {
+		return append(dst, payload...)
+	}
+	return s.aead.Seal(dst, s.nonce(), payload, nil)
+}
+
+// open 从连接读取并解码一块数据，收到空数据块时返回 nil
+func (s *vmessChunkStream) open(r io.Reader) ([]byte, error) {
+	if !s.chunk {
+		buf := make([]byte, vmessChunkSize)
+		n, err := r.Read(buf)
+		if n > 0 {
+			return buf[:n], nil
+		}
+		return nil, err
+	}
+
+	var sizeBuf [2]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(sizeBuf[:]) ^ s.nextMask())
+	if size < s.overhead() {
+		return nil, errors.New("VMess数据块长度错误")
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	if size == s.overhead() {
+		return nil, nil
+	}
+	if s.aead == nil {
+		return buf, nil
+	}
+
+	payload, err := s.aead.Open(buf[:0], s.nonce(), buf, nil)
+	if err != nil {
+		return nil, errors.New("VMess数据块解密失败")
+	}
+	return payload, nil
+}
+
+// parseUUID 解析 UUID 字符串
+// 与 Xray 行为一致，非标准格式的 ID (1-30 字节) 会映射为 UUIDv5
+func parseUUID(s string) ([16]byte, error) {
+	var id [16]byte
+
+	raw := strings.ReplaceAll(s, "-", "")
+	if len(raw) == 32 {
+		if _, err := hex.Decode(id[:], []byte(raw)); err == nil {
+			return id, nil
+		}
+	}
+
+	if len(s) == 0 || len(s) > 30 {
+		return id, fmt.Errorf("无效的UUID: %q", s)
+	}
+
+	// UUIDv5: SHA1(全零命名空间 + 名称)，并设置版本与变体位
+	sum := sha1.Sum(append(make([]byte, 16), s...))
+	copy(id[:], sum[:16])
+	id[6] = id[6]&0x0f | 0x50
+	id[8] = id[8]&0x3f | 0x80
+	return id, nil
+}
@@ -0,0 +1,196 @@
+package tester
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"proxy-tester/internal/parser"
+	"strings"
+	"testing"
+	"time"
+)
+
+// vmessResponse 测试服务器返回的响应头
+type vmessResponse int
+
+const (
+	vmessResponseValid    vmessResponse = iota
+	vmessResponseWrongV                 // 回显错误的响应认证字节
+	vmessResponseWrongKey               // 使用错误的密钥加密响应头
+)
+
+// vmessServerConn VMess 测试服务器端连接：解密 AEAD 认证头与请求头，复用客户端的分块编解码
+type vmessServerConn struct {
+	net.Conn
+	response vmessResponse
+
+	reader, writer  *vmessChunkStream
+	respKey, respIV [16]byte
+	respV           byte
+	headerSent      bool
+	pending         []byte
+}
+
+// handshake 使用 UUID 派生的指令密钥解密认证 ID 与请求头，UUID 不符时返回错误
+func (s *vmessServerConn) handshake(uuid string) error {
+	id, err := parseUUID(uuid)
+	if err != nil {
+		return err
+	}
+	cmdKey := md5.Sum(append(id[:], vmessCmdKeySalt...))
+
+	var authID [16]byte
+	if _, err := io.ReadFull(s.Conn, authID[:]); err != nil {
+		return err
+	}
+	var plain [16]byte
+	block, _ := aes.NewCipher(vmessKDF(cmdKey[:], vmessKDFAuthID)[:16])
+	block.Decrypt(plain[:], authID[:])
+	if crc32.ChecksumIEEE(plain[:12]) != binary.BigEndian.Uint32(plain[12:]) {
+		return errors.New("认证 ID 校验失败")
+	}
+	if diff := time.Since(time.Unix(int64(binary.BigEndian.Uint64(plain[:8])), 0)); diff > 2*time.Minute || diff < -2*time.Minute {
+		return errors.New("认证 ID 时间戳偏差过大")
+	}
+
+	buf := make([]byte, 18+8)
+	if _, err := io.ReadFull(s.Conn, buf); err != nil {
+		return err
+	}
+	sealedLength, nonce := buf[:18], string(buf[18:])
+	lengthAEAD := mustAESGCM(vmessKDF(cmdKey[:], vmessKDFHeaderLengthKey, string(authID[:]), nonce)[:16])
+	length, err := lengthAEAD.Open(nil, vmessKDF(cmdKey[:], vmessKDFHeaderLengthIV, string(authID[:]), nonce)[:12], sealedLength, authID[:])
+	if err != nil {
+		return err
+	}
+
+	sealedHeader := make([]byte, int(binary.BigEndian.Uint16(length))+16)
+	if _, err := io.ReadFull(s.Conn, sealedHeader); err != nil {
+		return err
+	}
+	headerAEAD := mustAESGCM(vmessKDF(cmdKey[:], vmessKDFHeaderKey, string(authID[:]), nonce)[:16])
+	header, err := headerAEAD.Open(nil, vmessKDF(cmdKey[:], vmessKDFHeaderIV, string(authID[:]), nonce)[:12], sealedHeader, authID[:])
+	if err != nil {
+		return err
+	}
+
+	// Ver IV(16) Key(16) V Opt P|Sec Rsv Cmd ... F(4)
+	checksum := fnv.New32a()
+	checksum.Write(header[:len(header)-4])
+	if len(header) < 38 || header[0] != vmessVersion || !bytes.Equal(checksum.Sum(nil), header[len(header)-4:]) {
+		return errors.New("无效的请求头")
+	}
+	var iv, key [16]byte
+	copy(iv[:], header[1:17])
+	copy(key[:], header[17:33])
+	s.respV = header[33]
+	option, security := header[34], header[35]&0x0f
+
+	respKey := sha256.Sum256(key[:])
+	respIV := sha256.Sum256(iv[:])
+	copy(s.respKey[:], respKey[:16])
+	copy(s.respIV[:], respIV[:16])
+	if s.reader, err = newVMessChunkStream(security, option, key, iv); err != nil {
+		return err
+	}
+	s.writer, err = newVMessChunkStream(security, option, s.respKey, s.respIV)
+	return err
+}
+
+func (s *vmessServerConn) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		chunk, err := s.reader.open(s.Conn)
+		if err != nil {
+			return 0, err
+		}
+		s.pending = chunk
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// Write 首次写入时附带加密的响应头
+func (s *vmessServerConn) Write(p []byte) (int, error) {
+	var out []byte
+	if !s.headerSent {
+		s.headerSent = true
+		header := []byte{s.respV, 0, 0, 0}
+		respKey := s.respKey
+		switch s.response {
+		case vmessResponseWrongV:
+			header[0]++
+		case vmessResponseWrongKey:
+			respKey[0]++
+		}
+		lengthAEAD := mustAESGCM(vmessKDF(respKey[:], vmessKDFRespHeaderLengthKey)[:16])
+		out = lengthAEAD.Seal(out, vmessKDF(s.respIV[:], vmessKDFRespHeaderLengthIV)[:12], binary.BigEndian.AppendUint16(nil, uint16(len(header))), nil)
+		headerAEAD := mustAESGCM(vmessKDF(respKey[:], vmessKDFRespHeaderKey)[:16])
+		out = headerAEAD.Seal(out, vmessKDF(s.respIV[:], vmessKDFRespHeaderIV)[:12], header, nil)
+	}
+	out = s.writer.seal(out, p)
+	if _, err := s.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// startVMessServer 启动使用指定 UUID 的 VMess 测试服务器
+// 认证失败时与真实服务器一样不返回任何数据，直接关闭连接
+func startVMessServer(t *testing.T, uuid string, response vmessResponse) string {
+	return startTCPServer(t, func(conn net.Conn) {
+		server := &vmessServerConn{Conn: conn, response: response}
+		if err := server.handshake(uuid); err != nil {
+			return
+		}
+		serveProbe(server)
+	})
+}
+
+func TestVMessHandshake(t *testing.T) {
+	const uuid = "b831381d-6324-4d53-ad4f-8cda48b30811"
+
+	tests := []struct {
+		name     string
+		uuid     string
+		method   string
+		response vmessResponse
+		want     Failure
+		wantErr  string
+	}{
+		{name: "aes-128-gcm", uuid: uuid, method: "auto", want: FailureNone},
+		{name: "chacha20", uuid: uuid, method: "chacha20-poly1305", want: FailureNone},
+		{name: "none", uuid: uuid, method: "none", want: FailureNone},
+		{name: "zero", uuid: uuid, method: "zero", want: FailureNone},
+		{name: "wrong-uuid", uuid: "00000000-0000-0000-0000-000000000000", method: "auto", want: FailureAuthRejected},
+		{name: "response-wrong-v", uuid: uuid, method: "auto", response: vmessResponseWrongV, want: FailureAuthRejected, wantErr: "VMess响应头校验失败"},
+		{name: "response-wrong-key", uuid: uuid, method: "auto", response: vmessResponseWrongKey, want: FailureAuthRejected, wantErr: "VMess响应头解密失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startVMessServer(t, uuid, tt.response)
+			node := &parser.Node{
+				Name:   tt.name,
+				Type:   parser.ProxyTypeVMess,
+				Server: "127.0.0.1",
+				Port:   port,
+				UUID:   tt.uuid,
+				Method: tt.method,
+			}
+			got, err := probeNode(t, node)
+			if got != tt.want {
+				t.Fatalf("失败原因为 %s (%v)，期望 %s", got, err, tt.want)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 %q 不包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
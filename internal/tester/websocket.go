@@ -0,0 +1,311 @@
+package tester
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy-tester/internal/parser"
+	"strconv"
+	"strings"
+)
+
+// WebSocket 帧操作码
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xa
+)
+
+// wsAcceptGUID 用于计算 Sec-WebSocket-Accept 的固定 GUID (RFC 6455)
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsEarlyDataHeader 默认承载早期数据的请求头 (与 Xray 一致)
+const wsEarlyDataHeader = "Sec-WebSocket-Protocol"
+
+// wsConn 基于 WebSocket 二进制帧收发数据的连接
+type wsConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	// 启用早期数据时握手推迟到首次写入
+	early *wsEarlyData
+
+	// 当前帧的读取状态
+	remaining int64
+	masked    bool
+	maskKey   [4]byte
+	maskPos   int
+}
+
+// wsEarlyData 推迟握手所需的参数
+type wsEarlyData struct {
+	host      string
+	path      string
+	maxLength int
+	header    string
+	timeline  *Timeline // 握手完成时记录传输层阶段
+}
+
+// dialWebSocket 在已建立的连接上完成 WebSocket 握手
+// host 为 Host 头，path 为请求路径
+func dialWebSocket(conn net.Conn, host string, path string) (*wsConn, error) {
+	c := &wsConn{Conn: conn}
+	if err := c.handshake(host, path, "", nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// dialWebSocketTransport 按节点的 ws 传输参数建立 WebSocket 连接
+// Host 未声明时与 SNI 的回退规则一致；启用早期数据 (路径中的 ?ed= 或显式配置) 时，
+// 首个数据包会经 Base64 编码放入握手请求头，握手与代理协议请求在同一个往返中完成
+func dialWebSocketTransport(conn net.Conn, node *parser.Node) (net.Conn, error) {
+	host := node.Transport.Host
+	if host == "" {
+		host = node.ServerName()
+	}
+
+	path, maxEarly := splitEarlyData(node.Transport.Path)
+	if node.Transport.MaxEarlyData > 0 {
+		maxEarly = node.Transport.MaxEarlyData
+	}
+	if maxEarly <= 0 {
+		return dialWebSocket(conn, host, path)
+	}
+
+	header := node.Transport.EarlyDataHeader
+	if header == "" {
+		header = wsEarlyDataHeader
+	}
+	return &wsConn{
+		Conn:  conn,
+		early: &wsEarlyData{host: host, path: path, maxLength: maxEarly, header: header},
+	}, nil
+}
+
+// splitEarlyData 从路径中取出 ed 查询参数，返回去除该参数后的路径与早期数据长度
+func splitEarlyData(path string) (string, int) {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path, 0
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil || !values.Has("ed") {
+		return path, 0
+	}
+
+	ed, _ := strconv.Atoi(values.Get("ed"))
+	values.Del("ed")
+	if len(values) > 0 {
+		base += "?" + values.Encode()
+	}
+	return base, ed
+}
+
+// handshake 发送 HTTP Upgrade 请求并校验响应，earlyData 非空时放入 earlyHeader 请求头
+func (c *wsConn) handshake(host string, path string, earlyHeader string, earlyData []byte) error {
+	if path == "" {
+		path = "/"
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"User-Agent: Mozilla/5.0\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n"
+	if len(earlyData) > 0 {
+		request += earlyHeader + ": " + base64.RawURLEncoding.EncodeToString(earlyData) + "\r\n"
+	}
+	request += "\r\n"
+	if _, err := io.WriteString(c.Conn, request); err != nil {
+		return fmt.Errorf("发送WebSocket握手失败: %w", err)
+	}
+
+	reader := bufio.NewReader(c.Conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return fmt.Errorf("读取WebSocket握手响应失败: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("WebSocket握手失败: HTTP %d (路径或Host错误)", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		return fmt.Errorf("WebSocket握手失败: Sec-WebSocket-Accept 校验错误")
+	}
+
+	c.reader = reader
+	return nil
+}
+
+// wsAcceptKey 计算握手响应中应返回的 Sec-WebSocket-Accept
+func wsAcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// Write 将数据封装为一个带掩码的二进制帧发送
+// 握手尚未完成时，数据开头不超过早期数据上限的部分随握手请求发送
+func (c *wsConn) Write(p []byte) (int, error) {
+	rest := p
+	if early := c.early; early != nil {
+		n := len(rest)
+		if n > early.maxLength {
+			n = early.maxLength
+		}
+		c.early = nil
+		if err := c.handshake(early.host, early.path, early.header, rest[:n]); err != nil {
+			return 0, atStage(StageTransport, err)
+		}
+		early.timeline.mark(StageTransport)
+		rest = rest[n:]
+		if len(rest) == 0 {
+			return len(p), nil
+		}
+	}
+
+	if err := c.writeFrame(wsOpBinary, rest); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// deferHandshake 启用早期数据时握手推迟到首次写入，此时由 Write 记录传输层阶段
+func (c *wsConn) deferHandshake(timeline *Timeline) bool {
+	if c.early == nil {
+		return false
+	}
+	c.early.timeline = timeline
+	return true
+}
+
+// writeFrame 发送单个客户端帧，客户端帧必须使用掩码
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+
+	length := len(payload)
+	switch {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// Read 读取数据帧的负载，自动处理控制帧
+func (c *wsConn) Read(p []byte) (int, error) {
+	if early := c.early; early != nil {
+		c.early = nil
+		if err := c.handshake(early.host, early.path, "", nil); err != nil {
+			return 0, atStage(StageTransport, err)
+		}
+	}
+
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.reader.Read(p)
+	if c.masked {
+		for i := 0; i < n; i++ {
+			p[i] ^= c.maskKey[c.maskPos%4]
+			c.maskPos++
+		}
+	}
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// nextFrame 读取下一个帧头，控制帧在此处理完毕
+func (c *wsConn) nextFrame() error {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return err
+	}
+
+	opcode := header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	c.masked = masked
+	c.maskPos = 0
+	if masked {
+		if _, err := io.ReadFull(c.reader, c.maskKey[:]); err != nil {
+			return err
+		}
+	}
+
+	switch opcode {
+	case wsOpBinary, wsOpText, wsOpContinuation:
+		c.remaining = length
+		return nil
+	case wsOpClose:
+		return io.EOF
+	case wsOpPing:
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return err
+		}
+		return c.writeFrame(wsOpPong, payload)
+	default:
+		// 忽略 pong 及未知帧
+		_, err := io.CopyN(io.Discard, c.reader, length)
+		return err
+	}
+}
@@ -0,0 +1,232 @@
+package tester
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"proxy-tester/internal/parser"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// XHTTP 上行模式
+const (
+	xhttpModePacketUp  = "packet-up"  // GET 下行 + 每次写入一个 POST 请求
+	xhttpModeStreamUp  = "stream-up"  // GET 下行 + 一个流式 POST 上行
+	xhttpModeStreamOne = "stream-one" // 单个流式 POST 同时承载上下行
+)
+
+// xhttpConn 基于 XHTTP (SplitHTTP) 的连接，上下行由同一会话下的不同 HTTP/2 请求承载
+type xhttpConn struct {
+	net.Conn // 底层 TCP/TLS 连接，用于设置超时与获取地址
+
+	client   *http2.ClientConn
+	download *h2Response
+	body     io.Reader
+
+	// stream-up 模式的上行请求体；为空时按 packet-up 逐个发送 POST
+	upload  *io.PipeWriter
+	session url.URL
+	seq     int
+
+	mu        sync.Mutex
+	uploadErr error
+	closeOnce sync.Once
+}
+
+// dialXHTTP 在已建立的连接上打开 XHTTP 会话
+// 与 Xray 一致，auto 模式在 REALITY 下使用 stream-one，否则使用 packet-up；
+// 路径或 Host 错误时服务器返回 404，填充长度不符时返回 400
+func dialXHTTP(conn net.Conn, node *parser.Node) (net.Conn, error) {
+	host := node.Transport.Host
+	if host == "" {
+		host = node.ServerName()
+	}
+	path, query, _ := strings.Cut(node.Transport.Path, "?")
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	base := url.URL{Scheme: h2Scheme(node), Host: host, Path: path, RawQuery: query}
+
+	mode := node.Transport.Mode
+	if mode == "" || mode == "auto" {
+		mode = xhttpModePacketUp
+		if node.Security.Type == "reality" {
+			mode = xhttpModeStreamOne
+		}
+	}
+
+	switch mode {
+	case xhttpModeStreamOne:
+		return newH2Stream(conn, newXHTTPRequest(http.MethodPost, base, http.NoBody), checkXHTTPResponse)
+	case xhttpModePacketUp, xhttpModeStreamUp:
+	default:
+		return nil, unsupported(fmt.Errorf("不支持的XHTTP模式: %s", mode))
+	}
+
+	client, err := newH2Client(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	session := base
+	session.Path += newSessionID()
+	c := &xhttpConn{
+		Conn:     conn,
+		client:   client,
+		download: roundTripAsync(client, newXHTTPRequest(http.MethodGet, session, nil)),
+		session:  session,
+	}
+	if mode == xhttpModeStreamUp {
+		reader, writer := io.Pipe()
+		req := newXHTTPRequest(http.MethodPost, session, reader)
+		req.ContentLength = -1
+		c.upload = writer
+		go c.watchUpload(roundTripAsync(client, req))
+	}
+	return c, nil
+}
+
+// newXHTTPRequest 构造携带随机填充的请求
+// 填充同时放入 Referer 与查询参数: 新版服务器校验 Referer 中的填充长度，
+// 1.8.x 服务器仅在查询参数含 x_padding 时才省略下行开头的 "ok"
+func newXHTTPRequest(method string, u url.URL, body io.Reader) *http.Request {
+	n, _ := rand.Int(rand.Reader, big.NewInt(901))
+	padding := "x_padding=" + strings.Repeat("X", 100+int(n.Int64()))
+
+	referer := u
+	referer.RawQuery = padding
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += padding
+
+	req := &http.Request{
+		Method: method,
+		URL:    &u,
+		Host:   u.Host,
+		Header: http.Header{},
+		Body:   http.NoBody,
+	}
+	if body != nil {
+		req.Body = io.NopCloser(body)
+	}
+	req.Header.Set("Referer", referer.String())
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	if body != nil {
+		// 与 Xray 一致，流式上行请求伪装为 gRPC
+		req.Header.Set("Content-Type", "application/grpc")
+	}
+	return req
+}
+
+// checkXHTTPResponse 校验下行响应头
+func checkXHTTPResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("XHTTP请求失败: HTTP %d (路径、Host或模式错误)", resp.StatusCode)
+	}
+	return nil
+}
+
+// newSessionID 生成随机 UUID 作为会话 ID
+func newSessionID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Write 发送上行数据：stream-up 写入流式请求体，packet-up 以带序号的 POST 请求发送
+// packet-up 不等待响应，服务器按序号重组数据包
+func (c *xhttpConn) Write(p []byte) (int, error) {
+	if err := c.uploadError(); err != nil {
+		return 0, err
+	}
+	if c.upload != nil {
+		return c.upload.Write(p)
+	}
+
+	u := c.session
+	u.Path += "/" + strconv.Itoa(c.seq)
+	c.seq++
+
+	req := newXHTTPRequest(http.MethodPost, u, nil)
+	req.Body = io.NopCloser(bytes.NewReader(bytes.Clone(p)))
+	req.ContentLength = int64(len(p))
+	go c.watchUpload(roundTripAsync(c.client, req))
+	return len(p), nil
+}
+
+// watchUpload 记录上行请求的失败原因，供读取失败时报告
+func (c *xhttpConn) watchUpload(r *h2Response) {
+	resp, err := r.wait()
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err = atStage(StageTransport, fmt.Errorf("XHTTP上行请求失败: HTTP %d (路径、Host或模式错误)", resp.StatusCode))
+		}
+	}
+	if err != nil {
+		c.mu.Lock()
+		if c.uploadErr == nil {
+			c.uploadErr = err
+		}
+		c.mu.Unlock()
+	}
+}
+
+// uploadError 返回已记录的上行错误
+func (c *xhttpConn) uploadError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.uploadErr
+}
+
+// Read 读取下行响应体；下行中断时优先报告上行失败的原因
+func (c *xhttpConn) Read(p []byte) (int, error) {
+	if c.body == nil {
+		resp, err := c.download.wait()
+		if err == nil {
+			err = atStage(StageTransport, checkXHTTPResponse(resp))
+		}
+		if err != nil {
+			if uploadErr := c.uploadError(); uploadErr != nil {
+				return 0, uploadErr
+			}
+			return 0, err
+		}
+		c.body = resp.Body
+	}
+
+	n, err := c.body.Read(p)
+	if err != nil {
+		if uploadErr := c.uploadError(); uploadErr != nil {
+			return n, uploadErr
+		}
+	}
+	return n, err
+}
+
+// Close 结束上下行请求并关闭底层连接
+func (c *xhttpConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.upload != nil {
+			c.upload.Close()
+		}
+		c.download.close()
+		c.client.Close()
+	})
+	return c.Conn.Close()
+}
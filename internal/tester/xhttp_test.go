@@ -0,0 +1,132 @@
+package tester
+
+import (
+	"io"
+	"net/http"
+	"proxy-tester/internal/parser"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// xhttpSession 服务器端的 XHTTP 会话：上行数据按序号重组后写入 upload，由下行请求读取
+type xhttpSession struct {
+	upload   *io.PipeWriter
+	download *io.PipeReader
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	next int
+}
+
+// xhttpServer 模拟 Xray 的 XHTTP 入站，支持 packet-up、stream-up 与 stream-one
+// 路径不符时返回 404
+type xhttpServer struct {
+	path string
+
+	mu       sync.Mutex
+	sessions map[string]*xhttpSession
+}
+
+// session 返回 ID 对应的会话，上下行请求可能以任意顺序到达，因此按需创建
+func (s *xhttpServer) session(id string) *xhttpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok {
+		return session
+	}
+	reader, writer := io.Pipe()
+	session := &xhttpSession{upload: writer, download: reader}
+	session.cond = sync.NewCond(&session.mu)
+	s.sessions[id] = session
+	return session
+}
+
+// writePacket 按序号顺序将 packet-up 的数据包写入上行
+func (s *xhttpSession) writePacket(seq int, data []byte) {
+	s.mu.Lock()
+	for s.next != seq {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+
+	s.upload.Write(data)
+
+	s.mu.Lock()
+	s.next++
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+func (s *xhttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutPrefix(r.URL.Path, s.path)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+
+	switch {
+	case rest == "" && r.Method == http.MethodPost:
+		// stream-one: 单个请求同时承载上下行
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		serveVLESS(&h2ServerStream{Reader: r.Body, w: w}, testUUID)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		session := s.session(parts[0])
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		serveVLESS(&h2ServerStream{Reader: session.download, w: w}, testUUID)
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		// stream-up: 流式上行
+		io.Copy(s.session(parts[0]).upload, r.Body)
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		// packet-up: 每个请求为一个带序号的数据包
+		seq, err := strconv.Atoi(parts[1])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return
+		}
+		s.session(parts[0]).writePacket(seq, data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestXHTTPTransport(t *testing.T) {
+	port := startH2CServer(t, &xhttpServer{path: "/xhttp/", sessions: make(map[string]*xhttpSession)})
+
+	tests := []struct {
+		name string
+		path string
+		mode string
+		want Failure
+	}{
+		{"packet-up", "/xhttp", xhttpModePacketUp, FailureNone},
+		{"stream-up", "/xhttp", xhttpModeStreamUp, FailureNone},
+		{"stream-one", "/xhttp", xhttpModeStreamOne, FailureNone},
+		{"auto", "/xhttp", "auto", FailureNone},
+		{"packet-up-wrong-path", "/other", xhttpModePacketUp, FailureTransport},
+		{"stream-up-wrong-path", "/other", xhttpModeStreamUp, FailureTransport},
+		{"stream-one-wrong-path", "/other", xhttpModeStreamOne, FailureTransport},
+		{"unknown-mode", "/xhttp", "stream-two", FailureUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectFailure(t, &parser.Node{
+				Name:      tt.name,
+				Type:      parser.ProxyTypeVLESS,
+				Server:    "127.0.0.1",
+				Port:      port,
+				UUID:      testUUID,
+				Network:   "xhttp",
+				Transport: parser.Transport{Path: tt.path, Mode: tt.mode},
+			}, tt.want)
+		})
+	}
+}